@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"syscall"
+	"unsafe"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	backdropClassName = "ShindowBackdrop"
+
+	wmPaint         = 0x000F
+	wmMouseActivate = 0x0021
+	maNoActivate    = 3
+	imageBitmap     = 0
+	lrLoadFromFile  = 0x0010
+	srcCopy         = 0x00CC0020
+	stretchHalftone = 4
+)
+
+var (
+	gdi32                 = windows.NewLazySystemDLL("gdi32.dll")
+	createSolidBrushProc  = gdi32.NewProc("CreateSolidBrush")
+	createCompatibleDC    = gdi32.NewProc("CreateCompatibleDC")
+	deleteDCProc          = gdi32.NewProc("DeleteDC")
+	selectObjectProc      = gdi32.NewProc("SelectObject")
+	getObjectProc         = gdi32.NewProc("GetObjectW")
+	setStretchBltModeProc = gdi32.NewProc("SetStretchBltMode")
+	stretchBltProc        = gdi32.NewProc("StretchBlt")
+	loadImageProc         = user32.NewProc("LoadImageW")
+	beginPaintProc        = user32.NewProc("BeginPaint")
+	endPaintProc          = user32.NewProc("EndPaint")
+
+	backdropClassErr error
+	isBackdropClass  bool
+	// backdropBitmap is the image stretched over every backdrop, 0 to show only the color
+	backdropBitmap     uintptr
+	backdropBitmapSize win.RECT
+	// backdrops maps a monitor to its backdrop window. Backdrops are owned by the GUI thread
+	backdrops = map[win.HMONITOR]windows.HWND{}
+
+	backdropWndProcPtr = syscall.NewCallback(func(hwnd windows.HWND, msg uint32, wParam uintptr, lParam uintptr) uintptr {
+		switch msg {
+		case wmPaint:
+			if backdropBitmap != 0 {
+				paintBackdrop(hwnd)
+				return 0
+			}
+		case wmMouseActivate:
+			// clicking the backdrop must not pull it above the clients
+			return maNoActivate
+		}
+		return win.DefWindowProc(hwnd, msg, wParam, lParam)
+	})
+)
+
+// paintStruct is a PAINTSTRUCT
+type paintStruct struct {
+	hdc         uintptr
+	fErase      int32
+	rcPaint     win.RECT
+	fRestore    int32
+	fIncUpdate  int32
+	rgbReserved [32]byte
+}
+
+// bitmap is a BITMAP
+type bitmap struct {
+	bmType       int32
+	bmWidth      int32
+	bmHeight     int32
+	bmWidthBytes int32
+	bmPlanes     uint16
+	bmBitsPixel  uint16
+	bmBits       uintptr
+}
+
+// registerBackdropClass registers the window class of backdrops once, filled with backdrop_color
+// and painted with backdrop_image if it loads
+func registerBackdropClass() error {
+	if isBackdropClass {
+		return backdropClassErr
+	}
+	isBackdropClass = true
+
+	color, err := config.ParseColor(cfg.BackdropColor)
+	if err != nil {
+		backdropClassErr = fmt.Errorf("backdrop_color: %w", err)
+		return backdropClassErr
+	}
+	brush, _, _ := createSolidBrushProc.Call(uintptr(color))
+	if cfg.BackdropImage != "" {
+		err = loadBackdropImage(cfg.BackdropImage)
+		if err != nil {
+			slog.Warn("Failed to load backdrop image, using the color", "path", cfg.BackdropImage, "error", err)
+		}
+	}
+
+	class := &win.WNDCLASSEX{
+		LpfnWndProc:   backdropWndProcPtr,
+		HInstance:     win.GetModuleHandle(nil),
+		HbrBackground: win.HBRUSH(brush),
+		LpszClassName: StringToUTF16Ptr(backdropClassName),
+	}
+	class.CbSize = uint32(unsafe.Sizeof(*class))
+	if win.RegisterClassEx(class) == 0 {
+		backdropClassErr = fmt.Errorf("RegisterClassEx: %w", syscall.GetLastError())
+	}
+	return backdropClassErr
+}
+
+// loadBackdropImage loads a .bmp to stretch over backdrops
+func loadBackdropImage(path string) error {
+	handle, _, err := loadImageProc.Call(0, uintptr(unsafe.Pointer(StringToUTF16Ptr(path))), imageBitmap, 0, 0, lrLoadFromFile)
+	if handle == 0 {
+		return fmt.Errorf("LoadImage: %w", err)
+	}
+	var bm bitmap
+	getObjectProc.Call(handle, unsafe.Sizeof(bm), uintptr(unsafe.Pointer(&bm)))
+	backdropBitmap = handle
+	backdropBitmapSize = win.RECT{Right: bm.bmWidth, Bottom: bm.bmHeight}
+	return nil
+}
+
+// paintBackdrop stretches the backdrop image over a backdrop window
+func paintBackdrop(hwnd windows.HWND) {
+	var ps paintStruct
+	hdc, _, _ := beginPaintProc.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&ps)))
+	defer endPaintProc.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&ps)))
+	if hdc == 0 {
+		return
+	}
+	mem, _, _ := createCompatibleDC.Call(hdc)
+	if mem == 0 {
+		return
+	}
+	defer deleteDCProc.Call(mem)
+	old, _, _ := selectObjectProc.Call(mem, backdropBitmap)
+	defer selectObjectProc.Call(mem, old)
+
+	var rect win.RECT
+	win.GetClientRect(hwnd, &rect)
+	setStretchBltModeProc.Call(hdc, stretchHalftone)
+	stretchBltProc.Call(hdc, 0, 0, uintptr(rect.Right), uintptr(rect.Bottom),
+		mem, 0, 0, uintptr(backdropBitmapSize.Right), uintptr(backdropBitmapSize.Bottom), srcCopy)
+}
+
+// updateBackdrops shows a backdrop directly beneath the lowest client on every monitor that has a
+// managed client of a layout with a backdrop, and hides the rest. It must be called on the GUI thread
+func updateBackdrops() {
+	clients := backdropClients()
+	lowest := map[win.HMONITOR]windows.HWND{}
+	if len(clients) > 0 {
+		enumWindows(func(h uintptr) bool {
+			hwnd := windows.HWND(h)
+			if !clients[hwnd] || win.IsIconic(hwnd) || !win.IsWindowVisible(hwnd) {
+				return true
+			}
+			// windows are enumerated from the top of the z-order down, so the last client seen on a monitor is its lowest
+			lowest[win.MonitorFromWindow(hwnd, win.MONITOR_DEFAULTTONEAREST)] = hwnd
+			return true
+		})
+	}
+
+	for hmon, hwnd := range backdrops {
+		if _, ok := lowest[hmon]; !ok {
+			win.ShowWindow(hwnd, win.SW_HIDE)
+		}
+	}
+	for hmon, client := range lowest {
+		err := showBackdrop(hmon, client)
+		if err != nil {
+			slog.Error("Failed to show backdrop", "monitor", monitorNumber(hmon), "error", err)
+		}
+	}
+}
+
+// pruneBackdrops destroys the backdrops of monitors that were disconnected. It must be called on the GUI thread
+func pruneBackdrops() {
+	connected := map[win.HMONITOR]bool{}
+	for _, hmon := range monitors() {
+		connected[hmon] = true
+	}
+	for hmon, hwnd := range backdrops {
+		if !connected[hmon] {
+			win.DestroyWindow(hwnd)
+			delete(backdrops, hmon)
+		}
+	}
+}
+
+// showBackdrop covers a monitor with its backdrop, directly beneath client
+func showBackdrop(hmon win.HMONITOR, client windows.HWND) error {
+	info, ok := monitorInfo(hmon)
+	if !ok {
+		return fmt.Errorf("GetMonitorInfo: %w", syscall.GetLastError())
+	}
+	hwnd, ok := backdrops[hmon]
+	if !ok {
+		err := registerBackdropClass()
+		if err != nil {
+			return err
+		}
+		hwnd = win.CreateWindowEx(win.WS_EX_TOOLWINDOW|win.WS_EX_NOACTIVATE, StringToUTF16Ptr(backdropClassName),
+			StringToUTF16Ptr("Shindow Backdrop"), win.WS_POPUP, 0, 0, 0, 0, 0, 0, win.GetModuleHandle(nil), nil)
+		if hwnd == 0 {
+			return fmt.Errorf("CreateWindowEx: %w", syscall.GetLastError())
+		}
+		backdrops[hmon] = hwnd
+	}
+	rect := info.RcMonitor
+	if !win.SetWindowPos(hwnd, client, rect.Left, rect.Top, rect.Right-rect.Left, rect.Bottom-rect.Top,
+		win.SWP_NOACTIVATE|win.SWP_SHOWWINDOW) {
+		return fmt.Errorf("SetWindowPos: %w", syscall.GetLastError())
+	}
+	return nil
+}
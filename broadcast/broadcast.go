@@ -0,0 +1,110 @@
+// Package broadcast decides which clients a key pressed in the main client is mirrored to. Delivery is left to a Sender,
+// so routing does not depend on PostMessage or real windows
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Key is a single key transition
+type Key struct {
+	VK         uint32 // virtual key code
+	Scan       uint32 // hardware scan code
+	IsUp       bool   // released rather than pressed
+	IsExtended bool   // an extended key, such as the right hand Ctrl or the arrow keys
+}
+
+// LParam returns the lParam of the WM_KEYDOWN or WM_KEYUP message for the key
+func (k Key) LParam() uintptr {
+	lParam := uintptr(1) | uintptr(k.Scan&0xFF)<<16
+	if k.IsExtended {
+		lParam |= 1 << 24
+	}
+	if k.IsUp {
+		// previous key state and transition state are both set on release
+		lParam |= 1<<30 | 1<<31
+	}
+	return lParam
+}
+
+// Client is a managed client keys can be mirrored to
+type Client struct {
+	HWND    uintptr
+	Slot    int    // 1 based layout slot, 0 if none
+	Profile string // profile name, empty if it was started by hand
+}
+
+// Sender delivers a key to a client's window
+type Sender interface {
+	Send(hwnd uintptr, key Key) error
+}
+
+// Router mirrors whitelisted keys from the source client to its targets
+type Router struct {
+	Keys   map[uint32]bool   // virtual keys that are mirrored, every other key only reaches the source
+	Target func(Client) bool // returns true for clients that receive keys, nil for every client
+	Sender Sender
+
+	isStopped atomic.Bool
+}
+
+// NewRouter returns a router mirroring keys to the clients target accepts
+func NewRouter(keys []uint32, target func(Client) bool, sender Sender) *Router {
+	r := &Router{
+		Keys:   map[uint32]bool{},
+		Target: target,
+		Sender: sender,
+	}
+	for _, key := range keys {
+		r.Keys[key] = true
+	}
+	return r
+}
+
+// Stop turns the router off, so keys still queued when broadcasting is toggled off are dropped
+func (r *Router) Stop() {
+	r.isStopped.Store(true)
+}
+
+// IsStopped returns true once Stop was called
+func (r *Router) IsStopped() bool {
+	return r.isStopped.Load()
+}
+
+// IsMirrored returns true if key is whitelisted and the router is on
+func (r *Router) IsMirrored(key Key) bool {
+	return r.Keys[key.VK] && !r.IsStopped()
+}
+
+// Targets returns the clients a key pressed in source is mirrored to
+func (r *Router) Targets(source Client, clients []Client, key Key) []Client {
+	if !r.IsMirrored(key) {
+		return nil
+	}
+	var targets []Client
+	for _, client := range clients {
+		if client.HWND == source.HWND {
+			continue
+		}
+		if r.Target != nil && !r.Target(client) {
+			continue
+		}
+		targets = append(targets, client)
+	}
+	return targets
+}
+
+// Route sends key to every target of source. Each target is tried even if an earlier one fails
+func (r *Router) Route(source Client, clients []Client, key Key) ([]Client, error) {
+	targets := r.Targets(source, clients, key)
+	var errs []error
+	for _, target := range targets {
+		err := r.Sender.Send(target.HWND, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("send to 0x%X: %w", target.HWND, err))
+		}
+	}
+	return targets, errors.Join(errs...)
+}
@@ -0,0 +1,153 @@
+package broadcast
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// sent is a key delivered by the recording sender
+type sent struct {
+	HWND uintptr
+	Key  Key
+}
+
+// recordingSender records every key it is asked to send, failing for the windows in fail
+type recordingSender struct {
+	sent []sent
+	fail map[uintptr]bool
+}
+
+func (s *recordingSender) Send(hwnd uintptr, key Key) error {
+	if s.fail[hwnd] {
+		return errors.New("window gone")
+	}
+	s.sent = append(s.sent, sent{HWND: hwnd, Key: key})
+	return nil
+}
+
+const (
+	vk1     = 0x31
+	vkSpace = 0x20
+	vkEnter = 0x0D
+)
+
+var (
+	mainClient = Client{HWND: 0x10, Slot: 1, Profile: "warrior"}
+	cleric     = Client{HWND: 0x20, Slot: 2, Profile: "cleric"}
+	wizard     = Client{HWND: 0x30, Slot: 3, Profile: "wizard"}
+	byHand     = Client{HWND: 0x40}
+	clients    = []Client{mainClient, cleric, wizard, byHand}
+)
+
+func TestRoute(t *testing.T) {
+	inSlots := func(slots ...int) func(Client) bool {
+		return func(client Client) bool {
+			for _, slot := range slots {
+				if client.Slot == slot {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	tests := []struct {
+		name   string
+		keys   []uint32
+		target func(Client) bool
+		source Client
+		key    Key
+		want   []uintptr
+	}{
+		{name: "whitelisted key reaches every other client", keys: []uint32{vk1, vkSpace}, source: mainClient, key: Key{VK: vk1}, want: []uintptr{0x20, 0x30, 0x40}},
+		{name: "key release is mirrored", keys: []uint32{vk1}, source: mainClient, key: Key{VK: vk1, IsUp: true}, want: []uintptr{0x20, 0x30, 0x40}},
+		{name: "key not whitelisted", keys: []uint32{vk1, vkSpace}, source: mainClient, key: Key{VK: vkEnter}},
+		{name: "empty whitelist", source: mainClient, key: Key{VK: vk1}},
+		{name: "target group", keys: []uint32{vk1}, target: inSlots(2, 3), source: mainClient, key: Key{VK: vk1}, want: []uintptr{0x20, 0x30}},
+		{name: "target group by profile", keys: []uint32{vk1}, target: func(c Client) bool { return c.Profile == "wizard" }, source: mainClient, key: Key{VK: vk1}, want: []uintptr{0x30}},
+		{name: "source in the target group is excluded", keys: []uint32{vk1}, target: inSlots(1, 2), source: mainClient, key: Key{VK: vk1}, want: []uintptr{0x20}},
+		{name: "group with only the source", keys: []uint32{vk1}, target: inSlots(1), source: mainClient, key: Key{VK: vk1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &recordingSender{}
+			router := NewRouter(tt.keys, tt.target, sender)
+
+			targets, err := router.Route(tt.source, clients, tt.key)
+			if err != nil {
+				t.Fatalf("route: %v", err)
+			}
+			var got []uintptr
+			for _, target := range targets {
+				got = append(got, target.HWND)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("targets: got %v, want %v", got, tt.want)
+			}
+
+			var delivered []uintptr
+			for _, s := range sender.sent {
+				if s.Key != tt.key {
+					t.Fatalf("sent %+v, want %+v", s.Key, tt.key)
+				}
+				delivered = append(delivered, s.HWND)
+			}
+			if !reflect.DeepEqual(delivered, tt.want) {
+				t.Fatalf("delivered: got %v, want %v", delivered, tt.want)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	sender := &recordingSender{}
+	router := NewRouter([]uint32{vk1}, nil, sender)
+	if !router.IsMirrored(Key{VK: vk1}) {
+		t.Fatalf("whitelisted key not mirrored before stop")
+	}
+
+	router.Stop()
+	if !router.IsStopped() || router.IsMirrored(Key{VK: vk1}) {
+		t.Fatalf("key still mirrored after stop")
+	}
+	targets, err := router.Route(mainClient, clients, Key{VK: vk1})
+	if err != nil || len(targets) > 0 || len(sender.sent) > 0 {
+		t.Fatalf("stopped router routed to %v, sent %v, error %v", targets, sender.sent, err)
+	}
+}
+
+func TestRouteFailures(t *testing.T) {
+	sender := &recordingSender{fail: map[uintptr]bool{0x20: true}}
+	router := NewRouter([]uint32{vk1}, nil, sender)
+
+	targets, err := router.Route(mainClient, clients, Key{VK: vk1})
+	if err == nil {
+		t.Fatalf("expected an error for the failed window")
+	}
+	if len(targets) != 3 {
+		t.Fatalf("targets: got %d, want 3", len(targets))
+	}
+	// the failure doesn't stop the clients after it from receiving the key
+	want := []sent{{HWND: 0x30, Key: Key{VK: vk1}}, {HWND: 0x40, Key: Key{VK: vk1}}}
+	if !reflect.DeepEqual(sender.sent, want) {
+		t.Fatalf("sent: got %v, want %v", sender.sent, want)
+	}
+}
+
+func TestLParam(t *testing.T) {
+	tests := []struct {
+		key  Key
+		want uintptr
+	}{
+		{Key{VK: vk1, Scan: 0x02}, 0x00020001},
+		{Key{VK: vk1, Scan: 0x02, IsUp: true}, 0xC0020001},
+		{Key{VK: 0x25, Scan: 0x4B, IsExtended: true}, 0x014B0001},
+	}
+	for _, tt := range tests {
+		got := tt.key.LParam()
+		if got != tt.want {
+			t.Errorf("LParam of %+v: got 0x%08X, want 0x%08X", tt.key, got, tt.want)
+		}
+	}
+}
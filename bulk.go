@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/wlk/walk"
+)
+
+// choices of the target box besides the configured groups
+const (
+	targetSelection = "Selection"
+	targetAll       = "All Clients"
+)
+
+var cmbTarget *walk.ComboBox
+
+// clientResult is the outcome of an action on one client
+type clientResult struct {
+	PID int
+	Err error
+}
+
+// targetNames returns the choices of the target box: the selected rows, every client, then each group
+func targetNames() []string {
+	names := []string{targetSelection, targetAll}
+	for _, group := range cfg.Groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+// targetPIDs returns the listed clients an action on target applies to, in list order
+func targetPIDs(target string) ([]int, error) {
+	switch target {
+	case targetSelection, "":
+		pids := lstDevicesModel.SelectedProcesses()
+		if len(pids) == 0 {
+			return nil, fmt.Errorf("no client selected")
+		}
+		return pids, nil
+	case targetAll:
+		return lstDevicesModel.PIDs(), nil
+	}
+	group := cfg.Group(target)
+	if group == nil {
+		return nil, fmt.Errorf("group %s not found", target)
+	}
+	pids := groupPIDs(group)
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("group %s has no running clients", group.Name)
+	}
+	return pids, nil
+}
+
+// groupPIDs returns the listed clients that belong to group, in list order
+func groupPIDs(group *config.Group) []int {
+	var pids []int
+	for _, pid := range lstDevicesModel.PIDs() {
+		slot, profile := managedMembership(pid)
+		if group.Matches(slot, profile) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// forEachClient runs fn on each client, carrying on past failures
+func forEachClient(action string, pids []int, fn func(pid int) error) []*clientResult {
+	results := make([]*clientResult, 0, len(pids))
+	for _, pid := range pids {
+		err := fn(pid)
+		if err != nil {
+			slog.Error("Failed to "+action, "pid", pid, "error", err)
+		}
+		results = append(results, &clientResult{PID: pid, Err: err})
+	}
+	return results
+}
+
+// resultsError joins the errors of every failed client, or returns nil if all succeeded
+func resultsError(results []*clientResult) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("pid %d: %w", result.PID, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runTargetAction runs fn on every client of the selected target and reports the outcome
+func runTargetAction(action string, fn func(pid int) error) {
+	pids, err := targetPIDs(cmbTarget.Text())
+	if err != nil {
+		errorBox("Failed to "+action, err)
+		return
+	}
+	reportResults(action, forEachClient(action, pids, fn))
+}
+
+// reportResults shows how an action went for each client. A single client is only reported if it failed,
+// the same way actions on the selection always were
+func reportResults(action string, results []*clientResult) {
+	if len(results) == 1 {
+		if results[0].Err != nil {
+			errorBox("Failed to "+action, results[0].Err)
+		}
+		return
+	}
+
+	failed := 0
+	lines := []string{}
+	for _, result := range results {
+		name := fmt.Sprintf("%d", result.PID)
+		if entry := lstDevicesModel.Entry(result.PID); entry != nil && entry.Title != "" {
+			name = fmt.Sprintf("%s (%d)", entry.Title, result.PID)
+		}
+		if result.Err != nil {
+			failed++
+			lines = append(lines, fmt.Sprintf("%s: failed, %v", name, result.Err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: ok", name))
+	}
+
+	title := fmt.Sprintf("%s%s: %d of %d succeeded", strings.ToUpper(action[:1]), action[1:], len(results)-failed, len(results))
+	style := walk.MsgBoxOK | walk.MsgBoxIconInformation
+	if failed > 0 {
+		style = walk.MsgBoxOK | walk.MsgBoxIconWarning
+	}
+	walk.MsgBox(settingsWnd, title, title+"\n\n"+strings.Join(lines, "\n"), style)
+}
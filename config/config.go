@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/xackery/shindow/fit"
 )
 
 type CastConfiguration struct {
@@ -21,20 +24,57 @@ type CastConfiguration struct {
 	EQWindowY int
 	EQWindowW int
 	EQWindowH int
+
+	IPCAddress string // loopback address for the control endpoint, empty disables it
+	IPCToken   string // secret every control request must carry, generated the first time the endpoint starts
+	LogLevel   string // debug, info, warn or error
+	LayoutGrid int    // pixels the layout editor snaps slots to, 0 disables the grid
+	MainSlot   int    // layout slot whose focus sends other clients to their background priority, 0 for any client
+
+	BackdropColor string // #rrggbb fill of the backdrop behind clients of layouts with a backdrop
+	BackdropImage string // bitmap stretched over the backdrop instead of the color, empty for none
+
+	// CursorHotkey releases the cursor from the client it is confined to and confines it again when pressed again,
+	// empty disables it. Set it, e.g. cursor_hotkey = Ctrl+Alt+C, along with confine_cursor = true in a rule.
+	// Alt-tab and focus loss release the cursor without it
+	CursorHotkey string
+
+	BroadcastKeys   string // keys mirrored from the main slot to other clients, e.g. 1-9, F1-F4, Space
+	BroadcastGroups string // comma separated groups that receive broadcast keys, empty for every other managed client
+	BroadcastHotkey string // toggles broadcasting, e.g. ScrollLock, empty disables it and broadcasting with it
+
+	MinimizeHotkey string // minimizes every managed client, empty disables it
+	RestoreHotkey  string // restores every managed client into its rect, empty disables it
+	HideHotkey     string // hides every managed client from the screen and taskbar, pressed again to bring them back, empty disables it
+
+	Layouts  []*Layout
+	Rules    []*Rule
+	Profiles []*Profile
+	Recipes  []*Recipe
+	Groups   []*Group
+}
+
+// section is a bracketed block in shindow.ini, such as [layout trio]
+type section interface {
+	parse(key string, value string) error
+	encode() string
 }
 
 // LoadCastConfig loads an shindow config file
 func LoadCastConfig(path string) (*CastConfiguration, error) {
-	fmt.Println("Loading config from", path)
+	slog.Info("Loading config", "path", path)
 	_, err := os.Stat(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return &CastConfiguration{
-				IsNew:     true,
-				EQWindowX: 0,
-				EQWindowY: 0,
-				EQWindowW: 1920,
-				EQWindowH: 1080,
+				IsNew:         true,
+				EQWindowX:     0,
+				EQWindowY:     0,
+				EQWindowW:     1920,
+				EQWindowH:     1080,
+				LogLevel:      "info",
+				LayoutGrid:    8,
+				BackdropColor: "#000000",
 			}, nil
 		} else {
 			return nil, fmt.Errorf("stat shindow.ini: %w", err)
@@ -48,7 +88,8 @@ func LoadCastConfig(path string) (*CastConfiguration, error) {
 	}
 	defer r.Close()
 
-	var config CastConfiguration
+	config := CastConfiguration{LogLevel: "info", LayoutGrid: 8, BackdropColor: "#000000"}
+	var current section
 
 	reader := bufio.NewScanner(r)
 	for reader.Scan() {
@@ -56,13 +97,27 @@ func LoadCastConfig(path string) (*CastConfiguration, error) {
 		if strings.HasPrefix(line, "#") {
 			continue
 		}
+		if isSectionHeader(line) {
+			current, err = config.newSection(line)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 		if strings.Contains(line, "=") {
-			parts := strings.Split(line, "=")
+			parts := strings.SplitN(line, "=", 2)
 			if len(parts) != 2 {
 				continue
 			}
 			key := strings.ToLower(strings.TrimSpace(parts[0]))
 			value := strings.TrimSpace(parts[1])
+			if current != nil {
+				err = current.parse(key, value)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
 			switch key {
 			case "settings_x":
 				config.SettingsX, err = strconv.Atoi(value)
@@ -105,6 +160,76 @@ func LoadCastConfig(path string) (*CastConfiguration, error) {
 				if err != nil {
 					return nil, fmt.Errorf("parse eq_window_h: %w", err)
 				}
+			case "ipc_address":
+				config.IPCAddress = value
+			case "ipc_token":
+				config.IPCToken = value
+			case "log_level":
+				var level slog.Level
+				err = level.UnmarshalText([]byte(value))
+				if err != nil {
+					return nil, fmt.Errorf("parse log_level: %w", err)
+				}
+				config.LogLevel = strings.ToLower(value)
+			case "layout_grid":
+				config.LayoutGrid, err = strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse layout_grid: %w", err)
+				}
+				if config.LayoutGrid < 0 {
+					return nil, fmt.Errorf("layout_grid must not be negative, got %d", config.LayoutGrid)
+				}
+			case "main_slot":
+				config.MainSlot, err = strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse main_slot: %w", err)
+				}
+			case "backdrop_color":
+				_, err = ParseColor(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse backdrop_color: %w", err)
+				}
+				config.BackdropColor = value
+			case "backdrop_image":
+				config.BackdropImage = value
+			case "cursor_hotkey":
+				_, err = ParseHotkey(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse cursor_hotkey: %w", err)
+				}
+				config.CursorHotkey = value
+			case "broadcast_keys":
+				_, err = ParseKeys(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse broadcast_keys: %w", err)
+				}
+				config.BroadcastKeys = value
+			case "broadcast_groups":
+				config.BroadcastGroups = value
+			case "broadcast_hotkey":
+				_, err = ParseHotkey(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse broadcast_hotkey: %w", err)
+				}
+				config.BroadcastHotkey = value
+			case "minimize_hotkey":
+				_, err = ParseHotkey(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse minimize_hotkey: %w", err)
+				}
+				config.MinimizeHotkey = value
+			case "restore_hotkey":
+				_, err = ParseHotkey(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse restore_hotkey: %w", err)
+				}
+				config.RestoreHotkey = value
+			case "hide_hotkey":
+				_, err = ParseHotkey(value)
+				if err != nil {
+					return nil, fmt.Errorf("parse hide_hotkey: %w", err)
+				}
+				config.HideHotkey = value
 
 			default:
 				return nil, fmt.Errorf("unknown key in shindow.ini: %s", key)
@@ -112,9 +237,121 @@ func LoadCastConfig(path string) (*CastConfiguration, error) {
 		}
 	}
 
+	err = config.validate()
+	if err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
+// validate checks references between sections once the whole file is read
+func (c *CastConfiguration) validate() error {
+	for _, layout := range c.Layouts {
+		switch {
+		case layout.Fit == fit.Integer && layout.Base.IsZero():
+			return fmt.Errorf("layout %s: fit integer needs a base resolution", layout.Name)
+		case (layout.Fit == fit.Aspect || layout.Fit == fit.Fill) && layout.Aspect.IsZero() && layout.Base.IsZero():
+			return fmt.Errorf("layout %s: fit %s needs an aspect ratio", layout.Name, layout.Fit)
+		}
+	}
+	for _, rule := range c.Rules {
+		if !c.hasRecipe(rule.Recipe) {
+			return fmt.Errorf("rule %s: recipe %s not found", rule.Name, rule.Recipe)
+		}
+	}
+	for _, profile := range c.Profiles {
+		if !c.hasRecipe(profile.Recipe) {
+			return fmt.Errorf("profile %s: recipe %s not found", profile.Name, profile.Recipe)
+		}
+	}
+	for _, name := range c.BroadcastGroupNames() {
+		if c.Group(name) == nil {
+			return fmt.Errorf("broadcast_groups: group %s not found", name)
+		}
+	}
+	return nil
+}
+
+// ParseColor parses a #rrggbb color to a Win32 COLORREF, which is ordered 0x00bbggrr
+func ParseColor(value string) (uint32, error) {
+	hex, ok := strings.CutPrefix(strings.TrimSpace(value), "#")
+	if !ok || len(hex) != 6 {
+		return 0, fmt.Errorf("expected #rrggbb, got %s", value)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("expected #rrggbb, got %s", value)
+	}
+	r := uint32(rgb>>16) & 0xFF
+	g := uint32(rgb>>8) & 0xFF
+	b := uint32(rgb) & 0xFF
+	return r | g<<8 | b<<16, nil
+}
+
+// isSectionHeader returns true if a line starts a section, e.g. [layout trio]
+func isSectionHeader(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]")
+}
+
+// newSection creates and registers the section a header line describes
+func (c *CastConfiguration) newSection(line string) (section, error) {
+	header := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(line), "["), "]")
+	kind, name, _ := strings.Cut(strings.TrimSpace(header), " ")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("section %s in shindow.ini has no name", line)
+	}
+	switch strings.ToLower(kind) {
+	case "layout":
+		layout := &Layout{Name: name}
+		c.Layouts = append(c.Layouts, layout)
+		return layout, nil
+	case "rule":
+		rule := &Rule{Name: name, DriftPolicy: DefaultRule.DriftPolicy, Maximized: DefaultRule.Maximized}
+		c.Rules = append(c.Rules, rule)
+		return rule, nil
+	case "profile":
+		profile := &Profile{Name: name}
+		c.Profiles = append(c.Profiles, profile)
+		return profile, nil
+	case "group":
+		group := &Group{Name: name}
+		c.Groups = append(c.Groups, group)
+		return group, nil
+	case "recipe":
+		if strings.EqualFold(name, ClassicBorderless.Name) {
+			return nil, fmt.Errorf("recipe %s is built in and can't be redefined", name)
+		}
+		recipe := &Recipe{Name: name, ZOrder: ZOrderNone, FrameChanged: true}
+		c.Recipes = append(c.Recipes, recipe)
+		return recipe, nil
+	}
+	return nil, fmt.Errorf("unknown section in shindow.ini: %s", kind)
+}
+
+// sections returns every section in the order it is saved
+func (c *CastConfiguration) sections() []section {
+	var sections []section
+	for _, layout := range c.Layouts {
+		sections = append(sections, layout)
+	}
+	for _, rule := range c.Rules {
+		sections = append(sections, rule)
+	}
+	for _, profile := range c.Profiles {
+		sections = append(sections, profile)
+	}
+	for _, recipe := range c.Recipes {
+		sections = append(sections, recipe)
+	}
+	for _, group := range c.Groups {
+		sections = append(sections, group)
+	}
+	return sections
+}
+
 // Save saves the config
 func (c *CastConfiguration) Save() error {
 	fi, err := os.Stat("shindow.ini")
@@ -144,6 +381,10 @@ func (c *CastConfiguration) Save() error {
 	reader := bufio.NewScanner(r)
 	for reader.Scan() {
 		line := reader.Text()
+		if isSectionHeader(line) {
+			// sections are always written from the config below
+			break
+		}
 		if strings.HasPrefix(line, "#") {
 			out += line + "\n"
 			continue
@@ -152,7 +393,7 @@ func (c *CastConfiguration) Save() error {
 			out += line + "\n"
 			continue
 		}
-		parts := strings.Split(line, "=")
+		parts := strings.SplitN(line, "=", 2)
 		if len(parts) != 2 {
 			continue
 		}
@@ -223,6 +464,118 @@ func (c *CastConfiguration) Save() error {
 			out += fmt.Sprintf("%s = %d\n", key, c.EQWindowH)
 			tmpConfig.EQWindowH = 1
 			continue
+		case "ipc_address":
+			if tmpConfig.IPCAddress == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.IPCAddress)
+			tmpConfig.IPCAddress = "1"
+			continue
+		case "ipc_token":
+			if tmpConfig.IPCToken == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.IPCToken)
+			tmpConfig.IPCToken = "1"
+			continue
+		case "log_level":
+			if tmpConfig.LogLevel == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.LogLevel)
+			tmpConfig.LogLevel = "1"
+			continue
+		case "layout_grid":
+			if tmpConfig.LayoutGrid == 1 {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %d\n", key, c.LayoutGrid)
+			tmpConfig.LayoutGrid = 1
+			continue
+		case "main_slot":
+			if tmpConfig.MainSlot == 1 {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %d\n", key, c.MainSlot)
+			tmpConfig.MainSlot = 1
+			continue
+		case "backdrop_color":
+			if tmpConfig.BackdropColor == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.BackdropColor)
+			tmpConfig.BackdropColor = "1"
+			continue
+		case "backdrop_image":
+			if tmpConfig.BackdropImage == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.BackdropImage)
+			tmpConfig.BackdropImage = "1"
+			continue
+		case "cursor_hotkey":
+			if tmpConfig.CursorHotkey == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.CursorHotkey)
+			tmpConfig.CursorHotkey = "1"
+			continue
+		case "broadcast_keys":
+			if tmpConfig.BroadcastKeys == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.BroadcastKeys)
+			tmpConfig.BroadcastKeys = "1"
+			continue
+		case "broadcast_groups":
+			if tmpConfig.BroadcastGroups == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.BroadcastGroups)
+			tmpConfig.BroadcastGroups = "1"
+			continue
+		case "broadcast_hotkey":
+			if tmpConfig.BroadcastHotkey == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.BroadcastHotkey)
+			tmpConfig.BroadcastHotkey = "1"
+			continue
+		case "minimize_hotkey":
+			if tmpConfig.MinimizeHotkey == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.MinimizeHotkey)
+			tmpConfig.MinimizeHotkey = "1"
+			continue
+		case "restore_hotkey":
+			if tmpConfig.RestoreHotkey == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.RestoreHotkey)
+			tmpConfig.RestoreHotkey = "1"
+			continue
+		case "hide_hotkey":
+			if tmpConfig.HideHotkey == "1" {
+				continue
+			}
+
+			out += fmt.Sprintf("%s = %s\n", key, c.HideHotkey)
+			tmpConfig.HideHotkey = "1"
+			continue
 		}
 
 		line = fmt.Sprintf("%s = %s", key, value)
@@ -262,6 +615,68 @@ func (c *CastConfiguration) Save() error {
 		out += fmt.Sprintf("eq_window_h = %d\n", c.EQWindowH)
 	}
 
+	if tmpConfig.IPCAddress != "1" {
+		out += fmt.Sprintf("ipc_address = %s\n", c.IPCAddress)
+	}
+
+	if tmpConfig.IPCToken != "1" {
+		out += fmt.Sprintf("ipc_token = %s\n", c.IPCToken)
+	}
+
+	if tmpConfig.LogLevel != "1" {
+		out += fmt.Sprintf("log_level = %s\n", c.LogLevel)
+	}
+
+	if tmpConfig.LayoutGrid != 1 {
+		out += fmt.Sprintf("layout_grid = %d\n", c.LayoutGrid)
+	}
+
+	if tmpConfig.MainSlot != 1 {
+		out += fmt.Sprintf("main_slot = %d\n", c.MainSlot)
+	}
+
+	if tmpConfig.BackdropColor != "1" {
+		out += fmt.Sprintf("backdrop_color = %s\n", c.BackdropColor)
+	}
+
+	if tmpConfig.BackdropImage != "1" {
+		out += fmt.Sprintf("backdrop_image = %s\n", c.BackdropImage)
+	}
+
+	if tmpConfig.CursorHotkey != "1" {
+		out += fmt.Sprintf("cursor_hotkey = %s\n", c.CursorHotkey)
+	}
+
+	if tmpConfig.BroadcastKeys != "1" {
+		out += fmt.Sprintf("broadcast_keys = %s\n", c.BroadcastKeys)
+	}
+
+	if tmpConfig.BroadcastGroups != "1" {
+		out += fmt.Sprintf("broadcast_groups = %s\n", c.BroadcastGroups)
+	}
+
+	if tmpConfig.BroadcastHotkey != "1" {
+		out += fmt.Sprintf("broadcast_hotkey = %s\n", c.BroadcastHotkey)
+	}
+
+	if tmpConfig.MinimizeHotkey != "1" {
+		out += fmt.Sprintf("minimize_hotkey = %s\n", c.MinimizeHotkey)
+	}
+
+	if tmpConfig.RestoreHotkey != "1" {
+		out += fmt.Sprintf("restore_hotkey = %s\n", c.RestoreHotkey)
+	}
+
+	if tmpConfig.HideHotkey != "1" {
+		out += fmt.Sprintf("hide_hotkey = %s\n", c.HideHotkey)
+	}
+
+	// trim blank lines left over from the previous save so they don't pile up
+	out = strings.TrimRight(out, "\n") + "\n"
+	for _, section := range c.sections() {
+		out += "\n" + section.encode()
+	}
+
 	err = os.WriteFile("shindow.ini", []byte(out), 0644)
 	if err != nil {
 		return fmt.Errorf("write file: %w", err)
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Priority is a process priority class
+type Priority string
+
+const (
+	PriorityIdle        Priority = "idle"
+	PriorityBelowNormal Priority = "below_normal"
+	PriorityNormal      Priority = "normal"
+	PriorityAboveNormal Priority = "above_normal"
+	PriorityHigh        Priority = "high"
+)
+
+// CPU is the affinity and priority a client runs with, and what changes while it is in the background,
+// i.e. while the main slot has focus
+type CPU struct {
+	Affinity           uint64   // mask of the cores the client may run on, 0 to leave it as is
+	Priority           Priority // priority class, empty to leave it as is
+	BackgroundPriority Priority // priority class in the background, empty to keep Priority
+	BackgroundAffinity uint64   // cores the client is narrowed to in the background, 0 to keep Affinity
+	// SuspendAfter suspends the client once it has been in the background this long, 0 never. A suspended client
+	// can't answer window messages, so it is resumed before Shindow moves, restyles, shows or renames its window,
+	// and suspended again after another SuspendAfter
+	SuspendAfter time.Duration
+}
+
+// HasBackground returns true if anything changes while the client is in the background
+func (c CPU) HasBackground() bool {
+	return c.BackgroundPriority != "" || c.BackgroundAffinity != 0 || c.SuspendAfter > 0
+}
+
+// Merge returns c with the settings o has overriding it
+func (c CPU) Merge(o CPU) CPU {
+	if o.Affinity != 0 {
+		c.Affinity = o.Affinity
+	}
+	if o.Priority != "" {
+		c.Priority = o.Priority
+	}
+	if o.BackgroundPriority != "" {
+		c.BackgroundPriority = o.BackgroundPriority
+	}
+	if o.BackgroundAffinity != 0 {
+		c.BackgroundAffinity = o.BackgroundAffinity
+	}
+	if o.SuspendAfter != 0 {
+		c.SuspendAfter = o.SuspendAfter
+	}
+	return c
+}
+
+// parse handles the cpu keys shared by rules and profiles, returning false if key isn't one of them
+func (c *CPU) parse(key string, value string) (bool, error) {
+	var err error
+	switch key {
+	case "affinity":
+		c.Affinity, err = ParseAffinity(value)
+		if err != nil {
+			return true, fmt.Errorf("parse affinity: %w", err)
+		}
+	case "priority":
+		c.Priority, err = parsePriority(value)
+		if err != nil {
+			return true, fmt.Errorf("parse priority: %w", err)
+		}
+	case "background_priority":
+		c.BackgroundPriority, err = parsePriority(value)
+		if err != nil {
+			return true, fmt.Errorf("parse background_priority: %w", err)
+		}
+	case "background_affinity":
+		c.BackgroundAffinity, err = ParseAffinity(value)
+		if err != nil {
+			return true, fmt.Errorf("parse background_affinity: %w", err)
+		}
+	case "suspend_after":
+		c.SuspendAfter, err = time.ParseDuration(value)
+		if err != nil {
+			return true, fmt.Errorf("parse suspend_after: %w", err)
+		}
+		if c.SuspendAfter < 0 {
+			return true, fmt.Errorf("suspend_after must not be negative, got %s", value)
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (c *CPU) encode() string {
+	out := ""
+	if c.Affinity != 0 {
+		out += fmt.Sprintf("affinity = %s\n", FormatAffinity(c.Affinity))
+	}
+	if c.Priority != "" {
+		out += fmt.Sprintf("priority = %s\n", c.Priority)
+	}
+	if c.BackgroundPriority != "" {
+		out += fmt.Sprintf("background_priority = %s\n", c.BackgroundPriority)
+	}
+	if c.BackgroundAffinity != 0 {
+		out += fmt.Sprintf("background_affinity = %s\n", FormatAffinity(c.BackgroundAffinity))
+	}
+	if c.SuspendAfter != 0 {
+		out += fmt.Sprintf("suspend_after = %s\n", c.SuspendAfter)
+	}
+	return out
+}
+
+// Priorities lists every priority class from lowest to highest
+var Priorities = []Priority{PriorityIdle, PriorityBelowNormal, PriorityNormal, PriorityAboveNormal, PriorityHigh}
+
+func parsePriority(value string) (Priority, error) {
+	priority := Priority(strings.ToLower(strings.TrimSpace(value)))
+	switch priority {
+	case PriorityIdle, PriorityBelowNormal, PriorityNormal, PriorityAboveNormal, PriorityHigh:
+		return priority, nil
+	}
+	return "", fmt.Errorf("unknown priority %s, expected idle, below_normal, normal, above_normal or high", value)
+}
+
+// ParseAffinity parses a core list, e.g. 0-3,6, or a hex mask, e.g. 0x4F
+func ParseAffinity(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if hex, ok := strings.CutPrefix(strings.ToLower(value), "0x"); ok {
+		mask, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("mask %s: %w", value, err)
+		}
+		if mask == 0 {
+			return 0, fmt.Errorf("mask %s has no cores", value)
+		}
+		return mask, nil
+	}
+
+	var mask uint64
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return 0, fmt.Errorf("core %s: %w", part, err)
+		}
+		to := from
+		if isRange {
+			to, err = strconv.Atoi(strings.TrimSpace(last))
+			if err != nil {
+				return 0, fmt.Errorf("core %s: %w", part, err)
+			}
+		}
+		if from < 0 || to > 63 || from > to {
+			return 0, fmt.Errorf("cores %s must be between 0 and 63", part)
+		}
+		for core := from; core <= to; core++ {
+			mask |= 1 << core
+		}
+	}
+	return mask, nil
+}
+
+// FormatAffinity formats a mask as a core list, e.g. 0-3,6
+func FormatAffinity(mask uint64) string {
+	var parts []string
+	for core := 0; core < 64; {
+		if mask&(1<<core) == 0 {
+			core++
+			continue
+		}
+		// extend over the run of set bits starting at core
+		run := bits.TrailingZeros64(^(mask >> core))
+		if run == 1 {
+			parts = append(parts, strconv.Itoa(core))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", core, core+run-1))
+		}
+		core += run
+	}
+	return strings.Join(parts, ",")
+}
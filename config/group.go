@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Group is a named set of clients, picked by layout slot or profile. A client started by hand that was never
+// placed by a layout has neither, so it joins no group until a layout is applied to it
+type Group struct {
+	Name     string
+	Slots    []int    // 1 based layout slots
+	Profiles []string // profile names, matched case insensitively
+}
+
+// Group returns the group with the given name, or nil if none exists
+func (c *CastConfiguration) Group(name string) *Group {
+	for _, group := range c.Groups {
+		if strings.EqualFold(group.Name, name) {
+			return group
+		}
+	}
+	return nil
+}
+
+// BroadcastGroupNames returns the groups listed in broadcast_groups
+func (c *CastConfiguration) BroadcastGroupNames() []string {
+	return splitList(c.BroadcastGroups)
+}
+
+// Matches returns true if a client in slot, launched from profile, belongs to the group.
+// slot is 0 and profile empty for clients without one
+func (g *Group) Matches(slot int, profile string) bool {
+	for _, s := range g.Slots {
+		if slot > 0 && s == slot {
+			return true
+		}
+	}
+	for _, p := range g.Profiles {
+		if profile != "" && strings.EqualFold(p, profile) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Group) parse(key string, value string) error {
+	switch key {
+	case "slots":
+		for _, field := range splitList(value) {
+			slot, err := strconv.Atoi(field)
+			if err != nil {
+				return fmt.Errorf("group %s: parse slots: %w", g.Name, err)
+			}
+			if slot < 1 {
+				return fmt.Errorf("group %s: slots are 1 based, got %d", g.Name, slot)
+			}
+			g.Slots = append(g.Slots, slot)
+		}
+	case "profiles":
+		g.Profiles = append(g.Profiles, splitList(value)...)
+	default:
+		return fmt.Errorf("unknown key in group %s: %s", g.Name, key)
+	}
+	return nil
+}
+
+func (g *Group) encode() string {
+	out := fmt.Sprintf("[group %s]\n", g.Name)
+	if len(g.Slots) > 0 {
+		slots := make([]string, len(g.Slots))
+		for i, slot := range g.Slots {
+			slots[i] = strconv.Itoa(slot)
+		}
+		out += fmt.Sprintf("slots = %s\n", strings.Join(slots, ", "))
+	}
+	if len(g.Profiles) > 0 {
+		out += fmt.Sprintf("profiles = %s\n", strings.Join(g.Profiles, ", "))
+	}
+	return out
+}
+
+// splitList splits a comma separated value, dropping blank entries
+func splitList(value string) []string {
+	var out []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" {
+			out = append(out, field)
+		}
+	}
+	return out
+}
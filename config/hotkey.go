@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hotkey modifiers, as taken by RegisterHotKey
+const (
+	ModAlt      = 0x0001
+	ModControl  = 0x0002
+	ModShift    = 0x0004
+	ModWin      = 0x0008
+	ModNoRepeat = 0x4000
+)
+
+// Hotkey is a global key combination such as Ctrl+Alt+C
+type Hotkey struct {
+	Modifiers uint32 // Mod* flags
+	Key       uint32 // virtual key code
+}
+
+var (
+	hotkeyModifiers = map[string]uint32{
+		"alt":     ModAlt,
+		"ctrl":    ModControl,
+		"control": ModControl,
+		"shift":   ModShift,
+		"win":     ModWin,
+	}
+
+	// hotkeyKeys are the named virtual keys, letters, digits and F keys are handled by ParseHotkey
+	hotkeyKeys = map[string]uint32{
+		"backspace":  0x08,
+		"tab":        0x09,
+		"enter":      0x0D,
+		"pause":      0x13,
+		"capslock":   0x14,
+		"escape":     0x1B,
+		"esc":        0x1B,
+		"space":      0x20,
+		"pageup":     0x21,
+		"pagedown":   0x22,
+		"end":        0x23,
+		"home":       0x24,
+		"left":       0x25,
+		"up":         0x26,
+		"right":      0x27,
+		"down":       0x28,
+		"insert":     0x2D,
+		"delete":     0x2E,
+		"multiply":   0x6A,
+		"add":        0x6B,
+		"subtract":   0x6D,
+		"decimal":    0x6E,
+		"divide":     0x6F,
+		"numlock":    0x90,
+		"scrolllock": 0x91,
+		"oem3":       0xC0, // ` on US keyboards
+	}
+)
+
+// ParseHotkey parses a key combination such as Ctrl+Alt+C, Shift+F12 or ScrollLock.
+// An empty value returns a zero Hotkey, which disables it
+func ParseHotkey(value string) (Hotkey, error) {
+	hotkey := Hotkey{}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return hotkey, nil
+	}
+	parts := strings.Split(value, "+")
+	for i, part := range parts {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if i < len(parts)-1 {
+			modifier, ok := hotkeyModifiers[name]
+			if !ok {
+				return hotkey, fmt.Errorf("unknown modifier %s in %s", part, value)
+			}
+			hotkey.Modifiers |= modifier
+			continue
+		}
+		key, ok := parseKey(name)
+		if !ok {
+			return hotkey, fmt.Errorf("unknown key %s in %s", part, value)
+		}
+		hotkey.Key = key
+	}
+	return hotkey, nil
+}
+
+// parseKey returns the virtual key code of a lower case key name
+func parseKey(name string) (uint32, bool) {
+	if key, ok := hotkeyKeys[name]; ok {
+		return key, true
+	}
+	if len(name) == 1 {
+		c := name[0]
+		switch {
+		case c >= 'a' && c <= 'z':
+			return uint32(c - 'a' + 'A'), true
+		case c >= '0' && c <= '9':
+			return uint32(c), true
+		}
+	}
+	var n int
+	_, err := fmt.Sscanf(name, "f%d", &n)
+	if err == nil && n >= 1 && n <= 24 && name == fmt.Sprintf("f%d", n) {
+		return uint32(0x70 + n - 1), true
+	}
+	_, err = fmt.Sscanf(name, "numpad%d", &n)
+	if err == nil && n >= 0 && n <= 9 && name == fmt.Sprintf("numpad%d", n) {
+		return uint32(0x60 + n), true
+	}
+	return 0, false
+}
+
+// IsZero returns true if the hotkey is disabled
+func (h Hotkey) IsZero() bool {
+	return h.Key == 0
+}
+
+// ParseKeys parses a comma separated list of key names and ranges, such as 1-9, F1-F4, Space,
+// to their virtual key codes
+func ParseKeys(value string) ([]uint32, error) {
+	var keys []uint32
+	for _, field := range splitList(value) {
+		from, to, isRange := strings.Cut(strings.ToLower(field), "-")
+		first, ok := parseKey(strings.TrimSpace(from))
+		if !ok {
+			return nil, fmt.Errorf("unknown key %s", field)
+		}
+		last := first
+		if isRange {
+			last, ok = parseKey(strings.TrimSpace(to))
+			if !ok || last < first {
+				return nil, fmt.Errorf("invalid key range %s", field)
+			}
+		}
+		for key := first; key <= last; key++ {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
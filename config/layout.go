@@ -0,0 +1,233 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/shindow/fit"
+)
+
+// Layout is a named arrangement of slots that clients are placed into
+type Layout struct {
+	Name       string
+	Slots      []*Slot
+	Fit        fit.Mode // how clients are sized to their slot
+	Aspect     fit.Size // aspect ratio for the aspect and fill modes, the base resolution's if unset
+	Base       fit.Size // resolution the integer mode scales
+	IsBackdrop bool     // show the backdrop on monitors with a client of this layout
+	Bezel      int      // pixels added per bezel to slots spanning monitors, see SpanRect
+	Monitors   int      // number of monitors the layout is made for, 0 for any. See LayoutForMonitors
+}
+
+// Slot is the rect a single client is placed into
+type Slot struct {
+	X    int
+	Y    int
+	W    int
+	H    int
+	Span []int // 1 based monitors the slot covers instead of its rect, resolved when the layout is applied
+}
+
+// Layout returns the layout with the given name, or nil if none exists
+func (c *CastConfiguration) Layout(name string) *Layout {
+	for _, layout := range c.Layouts {
+		if strings.EqualFold(layout.Name, name) {
+			return layout
+		}
+	}
+	return nil
+}
+
+// SetLayout replaces the layout with the same name, or adds it if none exists
+func (c *CastConfiguration) SetLayout(layout *Layout) {
+	for i, existing := range c.Layouts {
+		if strings.EqualFold(existing.Name, layout.Name) {
+			c.Layouts[i] = layout
+			return
+		}
+	}
+	c.Layouts = append(c.Layouts, layout)
+}
+
+// LayoutForMonitors returns the layout to apply after the monitors changed. A layout made for exactly this many
+// monitors wins, the current one first, then the current layout if it is made for any count. Layouts with a slot
+// spanning monitors that are gone are skipped. It returns nil if none fits
+func (c *CastConfiguration) LayoutForMonitors(monitors []fit.Rect, current string) *Layout {
+	var match *Layout
+	for _, layout := range c.Layouts {
+		if layout.Monitors != len(monitors) || !layout.resolves(monitors) {
+			continue
+		}
+		if strings.EqualFold(layout.Name, current) {
+			return layout
+		}
+		if match == nil {
+			match = layout
+		}
+	}
+	if match != nil {
+		return match
+	}
+	layout := c.Layout(current)
+	if layout != nil && layout.Monitors == 0 && layout.resolves(monitors) {
+		return layout
+	}
+	return nil
+}
+
+// resolves returns true if every slot of the layout can be placed on monitors
+func (l *Layout) resolves(monitors []fit.Rect) bool {
+	for i := range l.Slots {
+		_, err := l.SlotArea(i, monitors)
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Layout) parse(key string, value string) error {
+	switch key {
+	case "slot":
+		slot, err := parseSlot(value)
+		if err != nil {
+			return fmt.Errorf("parse slot: %w", err)
+		}
+		l.Slots = append(l.Slots, slot)
+	case "span":
+		span, err := parseSpan(value)
+		if err != nil {
+			return fmt.Errorf("layout %s: parse span: %w", l.Name, err)
+		}
+		l.Slots = append(l.Slots, &Slot{Span: span})
+	case "bezel":
+		bezel, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("layout %s: parse bezel: %w", l.Name, err)
+		}
+		if bezel < 0 {
+			return fmt.Errorf("layout %s: bezel must not be negative, got %d", l.Name, bezel)
+		}
+		l.Bezel = bezel
+	case "monitors":
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("layout %s: parse monitors: %w", l.Name, err)
+		}
+		if count < 0 {
+			return fmt.Errorf("layout %s: monitors must not be negative, got %d", l.Name, count)
+		}
+		l.Monitors = count
+	case "fit":
+		mode, err := fit.ParseMode(value)
+		if err != nil {
+			return fmt.Errorf("layout %s: %w", l.Name, err)
+		}
+		l.Fit = mode
+	case "aspect":
+		size, err := fit.ParseSize(value)
+		if err != nil {
+			return fmt.Errorf("parse aspect: %w", err)
+		}
+		l.Aspect = size
+	case "base":
+		size, err := fit.ParseSize(value)
+		if err != nil {
+			return fmt.Errorf("parse base: %w", err)
+		}
+		l.Base = size
+	case "backdrop":
+		isBackdrop, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("parse backdrop: %w", err)
+		}
+		l.IsBackdrop = isBackdrop
+	default:
+		return fmt.Errorf("unknown key in layout %s: %s", l.Name, key)
+	}
+	return nil
+}
+
+func (l *Layout) encode() string {
+	out := fmt.Sprintf("[layout %s]\n", l.Name)
+	if l.Fit != "" && l.Fit != fit.Stretch {
+		out += fmt.Sprintf("fit = %s\n", l.Fit)
+	}
+	if !l.Aspect.IsZero() {
+		out += fmt.Sprintf("aspect = %d:%d\n", l.Aspect.W, l.Aspect.H)
+	}
+	if !l.Base.IsZero() {
+		out += fmt.Sprintf("base = %s\n", l.Base)
+	}
+	if l.IsBackdrop {
+		out += "backdrop = true\n"
+	}
+	if l.Bezel != 0 {
+		out += fmt.Sprintf("bezel = %d\n", l.Bezel)
+	}
+	if l.Monitors != 0 {
+		out += fmt.Sprintf("monitors = %d\n", l.Monitors)
+	}
+	for _, slot := range l.Slots {
+		if len(slot.Span) > 0 {
+			span := make([]string, len(slot.Span))
+			for i, number := range slot.Span {
+				span[i] = strconv.Itoa(number)
+			}
+			out += fmt.Sprintf("span = %s\n", strings.Join(span, ", "))
+			continue
+		}
+		out += fmt.Sprintf("slot = %d,%d,%d,%d\n", slot.X, slot.Y, slot.W, slot.H)
+	}
+	return out
+}
+
+// SlotArea returns the rect of the slot at index before the fit mode, resolving slots that span monitors.
+// monitors holds the full rect of every display monitor in enumeration order
+func (l *Layout) SlotArea(index int, monitors []fit.Rect) (fit.Rect, error) {
+	slot := l.Slots[index]
+	if len(slot.Span) == 0 {
+		return fit.Rect{X: slot.X, Y: slot.Y, W: slot.W, H: slot.H}, nil
+	}
+	area, err := SpanRect(slot.Span, monitors, l.Bezel)
+	if err != nil {
+		return area, fmt.Errorf("layout %s: slot %d: %w", l.Name, index+1, err)
+	}
+	return area, nil
+}
+
+// PlacedSlot returns the rect a client in the slot at index is placed into after the layout's fit mode.
+// monitors holds the full rect of every display monitor in enumeration order, used by slots that span them
+func (l *Layout) PlacedSlot(index int, monitors []fit.Rect) (*Slot, error) {
+	area, err := l.SlotArea(index, monitors)
+	if err != nil {
+		return nil, err
+	}
+	size := l.Aspect
+	if l.Fit == fit.Integer || size.IsZero() {
+		size = l.Base
+	}
+	rect := fit.Resolve(area, l.Fit, size)
+	return &Slot{X: rect.X, Y: rect.Y, W: rect.W, H: rect.H}, nil
+}
+
+// parseSlot parses a slot in x,y,w,h format
+func parseSlot(value string) (*Slot, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expected x,y,w,h, got %s", value)
+	}
+	vals := make([]int, 4)
+	for i, part := range parts {
+		val, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "xywh"[i:i+1], err)
+		}
+		vals[i] = val
+	}
+	if vals[2] <= 0 || vals[3] <= 0 {
+		return nil, fmt.Errorf("width and height must be positive, got %dx%d", vals[2], vals[3])
+	}
+	return &Slot{X: vals[0], Y: vals[1], W: vals[2], H: vals[3]}, nil
+}
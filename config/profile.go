@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/shindow/fit"
+)
+
+// Profile describes how to launch a client and where to place it once its window appears
+type Profile struct {
+	Name         string
+	Exe          string
+	Dir          string   // working directory, defaults to the exe's directory
+	Args         string   // passed to the exe as is, e.g. patchme
+	Env          []string // KEY=VALUE pairs added to Shindow's environment
+	Layout       string   // layout the slot is taken from, empty to leave the window where it opens
+	Slot         int      // 1 based slot in Layout
+	IsBorderless bool
+	Recipe       string // recipe used to make the client borderless, empty to use the matching rule's
+	Char         string // character the client logs in as, shown by {char} in titles
+	Server       string // server the character is on, shown by {server} in titles
+	Title        string // title template, empty to use the matching rule's
+	CPU          CPU    // overrides the matching rule's
+	// ConfineCursor keeps the cursor inside the client while it has focus, nil to use the matching rule's
+	ConfineCursor *bool
+	// WriteEQClient writes the slot rect into the client's eqclient.ini before launch
+	WriteEQClient bool
+}
+
+// Profile returns the profile with the given name, or nil if none exists
+func (c *CastConfiguration) Profile(name string) *Profile {
+	for _, profile := range c.Profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile
+		}
+	}
+	return nil
+}
+
+// SlotRect returns the rect the profile is placed into within its layout slot, or nil if it has none.
+// monitors holds the full rect of every display monitor in enumeration order, see Layout.PlacedSlot
+func (c *CastConfiguration) SlotRect(profile *Profile, monitors []fit.Rect) (*Slot, error) {
+	if profile.Layout == "" {
+		return nil, nil
+	}
+	layout := c.Layout(profile.Layout)
+	if layout == nil {
+		return nil, fmt.Errorf("profile %s: layout %s not found", profile.Name, profile.Layout)
+	}
+	if profile.Slot < 1 || profile.Slot > len(layout.Slots) {
+		return nil, fmt.Errorf("profile %s: layout %s has no slot %d", profile.Name, layout.Name, profile.Slot)
+	}
+	return layout.PlacedSlot(profile.Slot-1, monitors)
+}
+
+func (p *Profile) parse(key string, value string) error {
+	var err error
+	switch key {
+	case "exe":
+		p.Exe = value
+	case "dir":
+		p.Dir = value
+	case "args":
+		p.Args = value
+	case "env":
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("profile %s: env must be KEY=VALUE, got %s", p.Name, value)
+		}
+		p.Env = append(p.Env, value)
+	case "layout":
+		p.Layout = value
+	case "slot":
+		p.Slot, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("profile %s: parse slot: %w", p.Name, err)
+		}
+	case "borderless":
+		p.IsBorderless, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("profile %s: parse borderless: %w", p.Name, err)
+		}
+	case "recipe":
+		p.Recipe = value
+	case "char":
+		p.Char = value
+	case "server":
+		p.Server = value
+	case "title":
+		p.Title = value
+	case "confine_cursor":
+		isConfined, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("profile %s: parse confine_cursor: %w", p.Name, err)
+		}
+		p.ConfineCursor = &isConfined
+	case "write_eqclient":
+		p.WriteEQClient, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("profile %s: parse write_eqclient: %w", p.Name, err)
+		}
+	default:
+		ok, err := p.CPU.parse(key, value)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		if !ok {
+			return fmt.Errorf("unknown key in profile %s: %s", p.Name, key)
+		}
+	}
+	return nil
+}
+
+func (p *Profile) encode() string {
+	out := fmt.Sprintf("[profile %s]\n", p.Name)
+	out += fmt.Sprintf("exe = %s\n", p.Exe)
+	if p.Dir != "" {
+		out += fmt.Sprintf("dir = %s\n", p.Dir)
+	}
+	if p.Args != "" {
+		out += fmt.Sprintf("args = %s\n", p.Args)
+	}
+	for _, env := range p.Env {
+		out += fmt.Sprintf("env = %s\n", env)
+	}
+	if p.Layout != "" {
+		out += fmt.Sprintf("layout = %s\n", p.Layout)
+		out += fmt.Sprintf("slot = %d\n", p.Slot)
+	}
+	out += fmt.Sprintf("borderless = %t\n", p.IsBorderless)
+	if p.Recipe != "" {
+		out += fmt.Sprintf("recipe = %s\n", p.Recipe)
+	}
+	if p.Char != "" {
+		out += fmt.Sprintf("char = %s\n", p.Char)
+	}
+	if p.Server != "" {
+		out += fmt.Sprintf("server = %s\n", p.Server)
+	}
+	if p.Title != "" {
+		out += fmt.Sprintf("title = %s\n", p.Title)
+	}
+	out += p.CPU.encode()
+	if p.ConfineCursor != nil {
+		out += fmt.Sprintf("confine_cursor = %t\n", *p.ConfineCursor)
+	}
+	out += fmt.Sprintf("write_eqclient = %t\n", p.WriteEQClient)
+	return out
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/shindow/winstyle"
+)
+
+// ZOrder is where a window is placed in the z-order when a recipe is applied
+type ZOrder string
+
+const (
+	ZOrderNone      ZOrder = "none" // leave the z-order alone
+	ZOrderTop       ZOrder = "top"
+	ZOrderBottom    ZOrder = "bottom"
+	ZOrderTopmost   ZOrder = "topmost"
+	ZOrderNoTopmost ZOrder = "notopmost"
+)
+
+// Recipe is the set of style changes that make a window borderless. Removing borderless undoes them
+type Recipe struct {
+	Name         string
+	Set          uint32 // GWL_STYLE flags to add
+	Clear        uint32 // GWL_STYLE flags to remove
+	ExSet        uint32 // GWL_EXSTYLE flags to add
+	ExClear      uint32 // GWL_EXSTYLE flags to remove
+	ZOrder       ZOrder
+	FrameChanged bool // send SWP_FRAMECHANGED so the new frame is recalculated
+}
+
+// ClassicBorderless is the built in recipe Shindow has always used
+var ClassicBorderless = &Recipe{
+	Name: "classic-borderless",
+	Clear: 0x00C00000 | // WS_CAPTION
+		0x00040000 | // WS_THICKFRAME (border)
+		0x00020000 | // WS_MINIMIZEBOX
+		0x00010000 | // WS_MAXIMIZEBOX
+		0x00080000, // WS_SYSMENU
+	ExClear: 0x00000001 | // WS_EX_DLGMODALFRAME
+		0x00000200 | // WS_EX_CLIENTEDGE
+		0x00020000, // WS_EX_STATICEDGE
+	ZOrder:       ZOrderNone,
+	FrameChanged: true,
+}
+
+// Recipe returns the recipe with the given name, or ClassicBorderless if name is empty or unknown
+func (c *CastConfiguration) Recipe(name string) *Recipe {
+	for _, recipe := range c.Recipes {
+		if strings.EqualFold(recipe.Name, name) {
+			return recipe
+		}
+	}
+	return ClassicBorderless
+}
+
+// hasRecipe returns true if name is empty, built in or configured
+func (c *CastConfiguration) hasRecipe(name string) bool {
+	return name == "" || strings.EqualFold(name, ClassicBorderless.Name) || c.Recipe(name) != ClassicBorderless
+}
+
+// Apply returns the styles with the recipe applied
+func (r *Recipe) Apply(style uint32, exStyle uint32) (uint32, uint32) {
+	return style&^r.Clear | r.Set, exStyle&^r.ExClear | r.ExSet
+}
+
+// Undo returns the styles with the recipe's changes reversed
+func (r *Recipe) Undo(style uint32, exStyle uint32) (uint32, uint32) {
+	return style&^r.Set | r.Clear, exStyle&^r.ExSet | r.ExClear
+}
+
+// StyleMask returns every GWL_STYLE bit the recipe touches
+func (r *Recipe) StyleMask() uint32 {
+	return r.Set | r.Clear
+}
+
+// ExStyleMask returns every GWL_EXSTYLE bit the recipe touches
+func (r *Recipe) ExStyleMask() uint32 {
+	return r.ExSet | r.ExClear
+}
+
+func (r *Recipe) parse(key string, value string) error {
+	var err error
+	switch key {
+	case "set":
+		r.Set, err = winstyle.ParseStyle(value)
+	case "clear":
+		r.Clear, err = winstyle.ParseStyle(value)
+	case "ex_set":
+		r.ExSet, err = winstyle.ParseExStyle(value)
+	case "ex_clear":
+		r.ExClear, err = winstyle.ParseExStyle(value)
+	case "z_order":
+		zOrder := ZOrder(strings.ToLower(value))
+		switch zOrder {
+		case ZOrderNone, ZOrderTop, ZOrderBottom, ZOrderTopmost, ZOrderNoTopmost:
+		default:
+			return fmt.Errorf("recipe %s: unknown z_order %s", r.Name, value)
+		}
+		r.ZOrder = zOrder
+	case "frame_changed":
+		r.FrameChanged, err = strconv.ParseBool(value)
+	default:
+		return fmt.Errorf("unknown key in recipe %s: %s", r.Name, key)
+	}
+	if err != nil {
+		return fmt.Errorf("recipe %s: parse %s: %w", r.Name, key, err)
+	}
+	return nil
+}
+
+func (r *Recipe) encode() string {
+	out := fmt.Sprintf("[recipe %s]\n", r.Name)
+	out += fmt.Sprintf("set = %s\n", winstyle.FormatStyle(r.Set))
+	out += fmt.Sprintf("clear = %s\n", winstyle.FormatStyle(r.Clear))
+	out += fmt.Sprintf("ex_set = %s\n", winstyle.FormatExStyle(r.ExSet))
+	out += fmt.Sprintf("ex_clear = %s\n", winstyle.FormatExStyle(r.ExClear))
+	out += fmt.Sprintf("z_order = %s\n", r.ZOrder)
+	out += fmt.Sprintf("frame_changed = %t\n", r.FrameChanged)
+	return out
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/shindow/winstyle"
+)
+
+// DriftPolicy is what happens when a game resets the style or rect Shindow applied
+type DriftPolicy string
+
+const (
+	DriftPolicyReapply DriftPolicy = "reapply" // apply the style and rect again
+	DriftPolicyNotify  DriftPolicy = "notify"  // tell the user
+	DriftPolicyIgnore  DriftPolicy = "ignore"  // only record it
+)
+
+// MaximizedPolicy is what happens when a maximized client is placed or made borderless
+type MaximizedPolicy string
+
+const (
+	MaximizedRemember MaximizedPolicy = "remember" // restore it first, and maximize it again when borderless is removed
+	MaximizedRestore  MaximizedPolicy = "restore"  // restore it first
+	MaximizedReject   MaximizedPolicy = "reject"   // refuse, asking the user to restore it
+)
+
+// Rule holds per client settings for clients matching its title and exe
+type Rule struct {
+	Name        string
+	MatchTitle  string // case insensitive substring of the window title, empty matches any
+	MatchExe    string // case insensitive substring of the exe path, empty matches any
+	DriftPolicy DriftPolicy
+	Maximized   MaximizedPolicy
+	Recipe      string // recipe used to make matching clients borderless, empty for classic-borderless
+	Title       string // title template of matching clients, e.g. {char} - {server} - {slot}, empty keeps the game's
+	CPU         CPU
+	// ConfineCursor keeps the cursor inside matching clients while they have focus
+	ConfineCursor bool
+
+	// style flags changed on top of the recipe, e.g. style_add = WS_POPUP
+	StyleAdd      uint32
+	StyleRemove   uint32
+	ExStyleAdd    uint32
+	ExStyleRemove uint32
+}
+
+// DefaultRule is used for clients no configured rule matches
+var DefaultRule = &Rule{
+	Name:        "default",
+	DriftPolicy: DriftPolicyNotify,
+	Maximized:   MaximizedRemember,
+}
+
+// MatchRule returns the first rule matching a client's title and exe path, or DefaultRule
+func (c *CastConfiguration) MatchRule(title string, exe string) *Rule {
+	for _, rule := range c.Rules {
+		if rule.Matches(title, exe) {
+			return rule
+		}
+	}
+	return DefaultRule
+}
+
+// Matches returns true if a client's title and exe path match the rule
+func (r *Rule) Matches(title string, exe string) bool {
+	if r.MatchTitle != "" && !strings.Contains(strings.ToLower(title), strings.ToLower(r.MatchTitle)) {
+		return false
+	}
+	if r.MatchExe != "" && !strings.Contains(strings.ToLower(exe), strings.ToLower(r.MatchExe)) {
+		return false
+	}
+	return true
+}
+
+func (r *Rule) parse(key string, value string) error {
+	switch key {
+	case "match_title":
+		r.MatchTitle = value
+	case "match_exe":
+		r.MatchExe = value
+	case "drift_policy":
+		policy := DriftPolicy(strings.ToLower(value))
+		switch policy {
+		case DriftPolicyReapply, DriftPolicyNotify, DriftPolicyIgnore:
+		default:
+			return fmt.Errorf("rule %s: unknown drift_policy %s", r.Name, value)
+		}
+		r.DriftPolicy = policy
+	case "maximized":
+		policy := MaximizedPolicy(strings.ToLower(value))
+		switch policy {
+		case MaximizedRemember, MaximizedRestore, MaximizedReject:
+		default:
+			return fmt.Errorf("rule %s: unknown maximized %s, expected remember, restore or reject", r.Name, value)
+		}
+		r.Maximized = policy
+	case "recipe":
+		r.Recipe = value
+	case "title":
+		r.Title = value
+	case "confine_cursor":
+		var err error
+		r.ConfineCursor, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("rule %s: parse confine_cursor: %w", r.Name, err)
+		}
+	case "style_add", "style_remove", "ex_style_add", "ex_style_remove":
+		return r.parseStyle(key, value)
+	default:
+		ok, err := r.CPU.parse(key, value)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		if !ok {
+			return fmt.Errorf("unknown key in rule %s: %s", r.Name, key)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) encode() string {
+	out := fmt.Sprintf("[rule %s]\n", r.Name)
+	if r.MatchTitle != "" {
+		out += fmt.Sprintf("match_title = %s\n", r.MatchTitle)
+	}
+	if r.MatchExe != "" {
+		out += fmt.Sprintf("match_exe = %s\n", r.MatchExe)
+	}
+	out += fmt.Sprintf("drift_policy = %s\n", r.DriftPolicy)
+	out += fmt.Sprintf("maximized = %s\n", r.Maximized)
+	if r.Recipe != "" {
+		out += fmt.Sprintf("recipe = %s\n", r.Recipe)
+	}
+	if r.Title != "" {
+		out += fmt.Sprintf("title = %s\n", r.Title)
+	}
+	out += r.CPU.encode()
+	if r.ConfineCursor {
+		out += fmt.Sprintf("confine_cursor = %t\n", r.ConfineCursor)
+	}
+	if r.StyleAdd != 0 {
+		out += fmt.Sprintf("style_add = %s\n", winstyle.FormatStyle(r.StyleAdd))
+	}
+	if r.StyleRemove != 0 {
+		out += fmt.Sprintf("style_remove = %s\n", winstyle.FormatStyle(r.StyleRemove))
+	}
+	if r.ExStyleAdd != 0 {
+		out += fmt.Sprintf("ex_style_add = %s\n", winstyle.FormatExStyle(r.ExStyleAdd))
+	}
+	if r.ExStyleRemove != 0 {
+		out += fmt.Sprintf("ex_style_remove = %s\n", winstyle.FormatExStyle(r.ExStyleRemove))
+	}
+	return out
+}
+
+// Adjust returns a copy of recipe with the rule's style changes merged in
+func (r *Rule) Adjust(recipe *Recipe) *Recipe {
+	adjusted := *recipe
+	adjusted.Set = adjusted.Set&^r.StyleRemove | r.StyleAdd
+	adjusted.Clear = adjusted.Clear&^r.StyleAdd | r.StyleRemove
+	adjusted.ExSet = adjusted.ExSet&^r.ExStyleRemove | r.ExStyleAdd
+	adjusted.ExClear = adjusted.ExClear&^r.ExStyleAdd | r.ExStyleRemove
+	return &adjusted
+}
+
+// parseStyle parses a list of style flag names such as WS_CAPTION|WS_SYSMENU
+func (r *Rule) parseStyle(key string, value string) error {
+	var err error
+	switch key {
+	case "style_add":
+		r.StyleAdd, err = winstyle.ParseStyle(value)
+	case "style_remove":
+		r.StyleRemove, err = winstyle.ParseStyle(value)
+	case "ex_style_add":
+		r.ExStyleAdd, err = winstyle.ParseExStyle(value)
+	case "ex_style_remove":
+		r.ExStyleRemove, err = winstyle.ParseExStyle(value)
+	}
+	if err != nil {
+		return fmt.Errorf("rule %s: parse %s: %w", r.Name, key, err)
+	}
+	return nil
+}
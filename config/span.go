@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/xackery/shindow/fit"
+)
+
+// SpanRect returns the rect a slot spanning monitors covers. monitors holds the full rect of every display monitor
+// in enumeration order, and span the 1 based numbers of the ones to cover. They must sit side by side in a row
+// with the same top and height, leaving no gap between neighbours.
+//
+// One window can't hide the pixels behind the bezels the way a driver's Eyefinity or Surround compensation does,
+// so a positive bezel instead widens the rect by that many pixels per gap, overflowing the outer edges evenly.
+// The game then renders the wider field of view the bezels hide, at the cost of cropping both ends
+func SpanRect(span []int, monitors []fit.Rect, bezel int) (fit.Rect, error) {
+	if len(span) == 0 {
+		return fit.Rect{}, fmt.Errorf("span has no monitors")
+	}
+	rects := make([]fit.Rect, 0, len(span))
+	for _, number := range span {
+		if number < 1 || number > len(monitors) {
+			return fit.Rect{}, fmt.Errorf("monitor %d not found, %d connected", number, len(monitors))
+		}
+		rects = append(rects, monitors[number-1])
+	}
+	sort.Slice(rects, func(i, j int) bool { return rects[i].X < rects[j].X })
+
+	union := rects[0]
+	for i, rect := range rects[1:] {
+		prev := rects[i]
+		if rect.Y != union.Y || rect.H != union.H {
+			return fit.Rect{}, fmt.Errorf("monitors must be the same height and top aligned, %s and %s differ", prev, rect)
+		}
+		if rect.X != prev.X+prev.W {
+			return fit.Rect{}, fmt.Errorf("monitors must be side by side with no gap, %s and %s are not", prev, rect)
+		}
+		union.W += rect.W
+	}
+
+	gaps := len(rects) - 1
+	union.X -= bezel * gaps / 2
+	union.W += bezel * gaps
+	return union, nil
+}
+
+// parseSpan parses a list of 1 based monitor numbers, such as 1,2,3
+func parseSpan(value string) ([]int, error) {
+	var span []int
+	seen := map[int]bool{}
+	for _, field := range splitList(value) {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("parse monitor: %w", err)
+		}
+		if number < 1 {
+			return nil, fmt.Errorf("monitors are 1 based, got %d", number)
+		}
+		if seen[number] {
+			return nil, fmt.Errorf("monitor %d listed twice", number)
+		}
+		seen[number] = true
+		span = append(span, number)
+	}
+	if len(span) == 0 {
+		return nil, fmt.Errorf("expected a list of monitors, got %s", value)
+	}
+	return span, nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xackery/shindow/fit"
+)
+
+func TestSpanRect(t *testing.T) {
+	left := fit.Rect{X: 0, Y: 0, W: 1920, H: 1080}
+	middle := fit.Rect{X: 1920, Y: 0, W: 1920, H: 1080}
+	right := fit.Rect{X: 3840, Y: 0, W: 1920, H: 1080}
+	// the primary monitor is enumerated first, so monitor 1 is not always the leftmost
+	row := []fit.Rect{middle, left, right}
+
+	tests := []struct {
+		name     string
+		span     []int
+		monitors []fit.Rect
+		bezel    int
+		want     fit.Rect
+		wantErr  bool
+	}{
+		{name: "single monitor", span: []int{1}, monitors: row, want: middle},
+		{name: "adjacent monitors", span: []int{2, 1}, monitors: row, want: fit.Rect{X: 0, Y: 0, W: 3840, H: 1080}},
+		{name: "whole row in any order", span: []int{3, 1, 2}, monitors: row, want: fit.Rect{X: 0, Y: 0, W: 5760, H: 1080}},
+		{name: "left of the primary", span: []int{1, 2}, monitors: []fit.Rect{left, {X: -1920, Y: 0, W: 1920, H: 1080}},
+			want: fit.Rect{X: -1920, Y: 0, W: 3840, H: 1080}},
+		{name: "bezel offset", span: []int{1, 2, 3}, monitors: row, bezel: 100, want: fit.Rect{X: -100, Y: 0, W: 5960, H: 1080}},
+		{name: "bezel on one gap", span: []int{1, 2}, monitors: row, bezel: 60, want: fit.Rect{X: -30, Y: 0, W: 3900, H: 1080}},
+		{name: "bezel ignored for one monitor", span: []int{3}, monitors: row, bezel: 100, want: right},
+		{name: "gapped monitors", span: []int{2, 3}, monitors: row, wantErr: true},
+		{name: "gap between uneven monitors", span: []int{1, 2}, monitors: []fit.Rect{left, {X: 2000, Y: 0, W: 1920, H: 1080}}, wantErr: true},
+		{name: "overlapping monitors", span: []int{1, 2}, monitors: []fit.Rect{left, {X: 1000, Y: 0, W: 1920, H: 1080}}, wantErr: true},
+		{name: "mismatched height", span: []int{1, 2}, monitors: []fit.Rect{left, {X: 1920, Y: 0, W: 2560, H: 1440}}, wantErr: true},
+		{name: "mismatched top", span: []int{1, 2}, monitors: []fit.Rect{left, {X: 1920, Y: 100, W: 1920, H: 1080}}, wantErr: true},
+		{name: "unknown monitor", span: []int{1, 4}, monitors: row, wantErr: true},
+		{name: "monitor zero", span: []int{0, 1}, monitors: row, wantErr: true},
+		{name: "no monitors connected", span: []int{1}, wantErr: true},
+		{name: "duplicate monitor", span: []int{1, 1}, monitors: row, wantErr: true},
+		{name: "empty span", monitors: row, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SpanRect(tt.span, tt.monitors, tt.bezel)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("SpanRect(%v) = %s, want an error", tt.span, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SpanRect(%v): %v", tt.span, err)
+			}
+			if got != tt.want {
+				t.Errorf("SpanRect(%v) = %s, want %s", tt.span, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSpan(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{value: "1", want: []int{1}},
+		{value: "1,2,3", want: []int{1, 2, 3}},
+		{value: " 3, 1 ,2 ", want: []int{3, 1, 2}},
+		{value: "1,,2", want: []int{1, 2}},
+		{value: "", wantErr: true},
+		{value: " , ", wantErr: true},
+		{value: "0,1", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "1,2,1", wantErr: true},
+		{value: "1,a", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSpan(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSpan(%q) = %v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSpan(%q): %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSpan(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+
+	"github.com/xackery/shindow/ipc"
+	"github.com/xackery/wlk/win"
+)
+
+// ipcController drives the same core functions the buttons call on behalf of IPC clients. Every operation that
+// changes a window runs on the GUI thread through syncUI, so it is serialized with button clicks
+type ipcController struct{}
+
+// startIPC starts the control endpoint if one is configured
+func startIPC() (*ipc.Server, error) {
+	if cfg.IPCAddress == "" {
+		return nil, nil
+	}
+	if cfg.IPCToken == "" {
+		token, err := newIPCToken()
+		if err != nil {
+			return nil, fmt.Errorf("new token: %w", err)
+		}
+		cfg.IPCToken = token
+		err = cfg.Save()
+		if err != nil {
+			return nil, fmt.Errorf("save token: %w", err)
+		}
+		slog.Info("Created ipc_token in shindow.ini, send it as a bearer token with every ipc request")
+	}
+	server, err := ipc.Listen(cfg.IPCAddress, cfg.IPCToken, &ipcController{})
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	go func() {
+		err := server.Serve()
+		if err != nil {
+			slog.Error("Failed to serve ipc", "error", err)
+		}
+	}()
+	slog.Info("Listening for ipc", "address", server.Addr())
+	return server, nil
+}
+
+// newIPCToken returns a random secret for ipc_token
+func newIPCToken() (string, error) {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Clients lists every running client with its window
+func (c *ipcController) Clients() ([]*ipc.ClientInfo, error) {
+	processes, err := listProcesses()
+	if err != nil {
+		return nil, fmt.Errorf("list processes: %w", err)
+	}
+	clients := []*ipc.ClientInfo{}
+	for _, process := range processes {
+		clients = append(clients, &ipc.ClientInfo{
+			PID:          process.PID,
+			Name:         process.Name,
+			HWND:         uint64(process.HWND),
+			Rect:         ipcRect(process.Rect),
+			IsBorderless: process.IsBorderless,
+		})
+	}
+	return clients, nil
+}
+
+// ApplyRect moves a client without changing its style
+func (c *ipcController) ApplyRect(pid int, rect ipc.Rect) error {
+	return syncUI(func() error {
+		hwnd, err := hwndByPID(pid)
+		if err != nil {
+			return err
+		}
+		return ApplyPlacements([]*Placement{{HWND: hwnd, Rect: winRect(rect)}})
+	})
+}
+
+// ToggleBorderless strips or restores a client's frame
+func (c *ipcController) ToggleBorderless(pid int, isBorderless bool, rect ipc.Rect) error {
+	return syncUI(func() error {
+		hwnd, err := hwndByPID(pid)
+		if err != nil {
+			return err
+		}
+		return ToggleBorderlessWindow(hwnd, isBorderless, winRect(rect))
+	})
+}
+
+// ApplyLayout places the listed clients into the named layout
+func (c *ipcController) ApplyLayout(name string) error {
+	layout := cfg.Layout(name)
+	if layout == nil {
+		return fmt.Errorf("layout %s not found", name)
+	}
+	return syncUI(func() error {
+		results, err := applyLayout(layout, lstDevicesModel.PIDs())
+		if err != nil {
+			return err
+		}
+		return resultsError(results)
+	})
+}
+
+// Status reports the version, client count and known layouts
+func (c *ipcController) Status() (*ipc.Status, error) {
+	processes, err := listProcesses()
+	if err != nil {
+		return nil, fmt.Errorf("list processes: %w", err)
+	}
+	return &ipc.Status{
+		Version:       Version,
+		Clients:       len(processes),
+		Layouts:       layoutNames(),
+		FocusPolicies: isFocusPoliciesEnabled(),
+	}, nil
+}
+
+// SetWindowState minimizes, restores or hides every managed client
+func (c *ipcController) SetWindowState(state string) error {
+	return syncUI(func() error {
+		return setWindowState(state)
+	})
+}
+
+// SetFocusPolicies pauses or resumes the focus policies
+func (c *ipcController) SetFocusPolicies(isEnabled bool) error {
+	return syncUI(func() error {
+		setFocusPolicies(isEnabled)
+		return nil
+	})
+}
+
+// syncUI runs fn on the GUI thread and waits for it to return. It must not be called from the GUI thread
+func syncUI(fn func() error) error {
+	done := make(chan error, 1)
+	settingsWnd.Synchronize(func() {
+		done <- fn()
+	})
+	return <-done
+}
+
+// isBorderlessStyle returns true if a window style has no title bar
+func isBorderlessStyle(style int32) bool {
+	return style&win.WS_CAPTION == 0
+}
+
+func winRect(rect ipc.Rect) win.RECT {
+	return win.RECT{
+		Left:   int32(rect.X),
+		Top:    int32(rect.Y),
+		Right:  int32(rect.X + rect.W),
+		Bottom: int32(rect.Y + rect.H),
+	}
+}
+
+func ipcRect(rect win.RECT) ipc.Rect {
+	return ipc.Rect{
+		X: int(rect.Left),
+		Y: int(rect.Top),
+		W: int(rect.Right - rect.Left),
+		H: int(rect.Bottom - rect.Top),
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/xackery/shindow/config"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	setProcessAffinityMaskProc = kernel32.NewProc("SetProcessAffinityMask")
+	getProcessAffinityMaskProc = kernel32.NewProc("GetProcessAffinityMask")
+
+	priorityClasses = map[config.Priority]uint32{
+		config.PriorityIdle:        windows.IDLE_PRIORITY_CLASS,
+		config.PriorityBelowNormal: windows.BELOW_NORMAL_PRIORITY_CLASS,
+		config.PriorityNormal:      windows.NORMAL_PRIORITY_CLASS,
+		config.PriorityAboveNormal: windows.ABOVE_NORMAL_PRIORITY_CLASS,
+		config.PriorityHigh:        windows.HIGH_PRIORITY_CLASS,
+	}
+)
+
+// cpuClient is a detected client and the cpu settings applied to it
+type cpuClient struct {
+	PID          int
+	CPU          config.CPU
+	isBackground bool            // focus policies are applied, see setBackground
+	isSuspended  bool            // suspended as an idle alt
+	affinity     uint64          // affinity before it was narrowed in the background
+	priority     config.Priority // priority before it was dropped in the background
+	suspendTimer *time.Timer     // pending suspension while in the background
+}
+
+var (
+	cpuMu      sync.Mutex
+	cpuClients = map[int]*cpuClient{}
+)
+
+// setPriority sets the priority class of a process
+func setPriority(pid int, priority config.Priority) error {
+	class, ok := priorityClasses[priority]
+	if !ok {
+		return fmt.Errorf("unknown priority %s", priority)
+	}
+	proc, err := windows.OpenProcess(windows.PROCESS_SET_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("OpenProcess %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(proc)
+	err = windows.SetPriorityClass(proc, class)
+	if err != nil {
+		return fmt.Errorf("SetPriorityClass %s: %w", priority, err)
+	}
+	return nil
+}
+
+// processPriority returns the priority class of a process
+func processPriority(pid int) (config.Priority, error) {
+	proc, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return "", fmt.Errorf("OpenProcess %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(proc)
+	class, err := windows.GetPriorityClass(proc)
+	if err != nil {
+		return "", fmt.Errorf("GetPriorityClass: %w", err)
+	}
+	for priority, c := range priorityClasses {
+		if c == class {
+			return priority, nil
+		}
+	}
+	return "", fmt.Errorf("unknown priority class 0x%x", class)
+}
+
+// setAffinity limits a process to the cores in mask
+func setAffinity(pid int, mask uint64) error {
+	proc, err := windows.OpenProcess(windows.PROCESS_SET_INFORMATION|windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("OpenProcess %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(proc)
+	ret, _, err := setProcessAffinityMaskProc.Call(uintptr(proc), uintptr(mask))
+	if ret == 0 {
+		return fmt.Errorf("SetProcessAffinityMask %s: %w", config.FormatAffinity(mask), err)
+	}
+	return nil
+}
+
+// processAffinity returns the cores a process may run on
+func processAffinity(pid int) (uint64, error) {
+	proc, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return 0, fmt.Errorf("OpenProcess %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(proc)
+	var mask, systemMask uintptr
+	ret, _, err := getProcessAffinityMaskProc.Call(uintptr(proc), uintptr(unsafe.Pointer(&mask)), uintptr(unsafe.Pointer(&systemMask)))
+	if ret == 0 {
+		return 0, fmt.Errorf("GetProcessAffinityMask: %w", err)
+	}
+	return uint64(mask), nil
+}
+
+// setClientPriority sets a client's priority by hand. It becomes the priority focus policies restore
+func setClientPriority(pid int, priority config.Priority) error {
+	err := setPriority(pid, priority)
+	if err != nil {
+		return err
+	}
+	cpuMu.Lock()
+	defer cpuMu.Unlock()
+	if client, ok := cpuClients[pid]; ok {
+		client.CPU.Priority = priority
+	}
+	slog.Info("Set priority", "pid", pid, "priority", priority)
+	return nil
+}
+
+// clientCPU returns the cpu settings of a client's rule, overridden by its profile's
+func clientCPU(hwnd windows.HWND, profile *config.Profile) config.CPU {
+	settings := ruleForWindow(hwnd).CPU
+	if profile != nil {
+		settings = settings.Merge(profile.CPU)
+	}
+	return settings
+}
+
+// tuneClient applies the cpu settings of a newly detected client. profile is nil for clients Shindow didn't launch,
+// otherwise its settings replace those a refresh may have applied first
+func tuneClient(pid int, hwnd windows.HWND, profile *config.Profile) {
+	settings := clientCPU(hwnd, profile)
+	cpuMu.Lock()
+	old, ok := cpuClients[pid]
+	if ok && profile == nil {
+		cpuMu.Unlock()
+		return
+	}
+	if ok && old.isBackground {
+		old.setBackground(false)
+	}
+	if !ok && settings.SuspendAfter > 0 {
+		// a previous run may have exited while the client was suspended
+		err := resumeProcess(pid)
+		if err != nil {
+			slog.Warn("Failed to resume client", "pid", pid, "error", err)
+		}
+	}
+	cpuClients[pid] = &cpuClient{PID: pid, CPU: settings}
+	cpuMu.Unlock()
+
+	if settings.Affinity != 0 {
+		err := setAffinity(pid, settings.Affinity)
+		if err != nil {
+			slog.Error("Failed to set affinity", "pid", pid, "affinity", config.FormatAffinity(settings.Affinity), "error", err)
+		} else {
+			slog.Info("Set affinity", "pid", pid, "affinity", config.FormatAffinity(settings.Affinity))
+		}
+	}
+	if settings.Priority != "" {
+		err := setPriority(pid, settings.Priority)
+		if err != nil {
+			slog.Error("Failed to set priority", "pid", pid, "priority", settings.Priority, "error", err)
+		} else {
+			slog.Info("Set priority", "pid", pid, "priority", settings.Priority)
+		}
+	}
+}
+
+// tuneClients applies cpu settings to clients with a window seen for the first time, and forgets clients that exited
+func tuneClients(processes []*ProcessEntry) {
+	seen := map[int]bool{}
+	for _, process := range processes {
+		seen[process.PID] = true
+		// rules match on the title, so wait for the window
+		if process.HWND != 0 {
+			tuneClient(process.PID, process.HWND, nil)
+		}
+	}
+	cpuMu.Lock()
+	defer cpuMu.Unlock()
+	for pid, client := range cpuClients {
+		if !seen[pid] {
+			if client.suspendTimer != nil {
+				client.suspendTimer.Stop()
+			}
+			delete(cpuClients, pid)
+		}
+	}
+}
+
+// managedSlot returns the layout slot of a managed client's window, or 0 if it has none
+func managedSlot(pid int) int {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	for _, client := range managedClients {
+		if client.PID == pid && client.Slot > 0 {
+			return client.Slot
+		}
+	}
+	return 0
+}
+
+// priorityNames returns the name of every priority class from lowest to highest
+func priorityNames() []string {
+	names := []string{}
+	for _, priority := range config.Priorities {
+		names = append(names, string(priority))
+	}
+	return names
+}
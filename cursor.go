@@ -0,0 +1,147 @@
+package main
+
+import (
+	"log/slog"
+	"unsafe"
+
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+var clipCursorProc = user32.NewProc("ClipCursor")
+
+// cursor confinement is only touched on the event thread, so it needs no lock
+var (
+	confinedHWND     windows.HWND // client the cursor is confined to, 0 if it is free
+	confinedRect     win.RECT
+	isCursorReleased bool // released by hotkey until it is pressed again
+	isMoveSizing     bool // the confined client is being dragged or resized
+)
+
+// clipCursor confines the cursor to rect in screen coordinates, or frees it if rect is nil
+func clipCursor(rect *win.RECT) error {
+	ret, _, err := clipCursorProc.Call(uintptr(unsafe.Pointer(rect)))
+	if ret == 0 {
+		return err
+	}
+	return nil
+}
+
+// isCursorConfined returns true if a managed client keeps the cursor inside it while focused.
+// A profile's setting overrides its rule's
+func isCursorConfined(hwnd windows.HWND) bool {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	client, ok := managedClients[hwnd]
+	if !ok {
+		return false
+	}
+	if client.Profile != nil && client.Profile.ConfineCursor != nil {
+		return *client.Profile.ConfineCursor
+	}
+	return client.Rule != nil && client.Rule.ConfineCursor
+}
+
+// clientScreenRect returns a window's client area in screen coordinates
+func clientScreenRect(hwnd windows.HWND) (win.RECT, bool) {
+	var rect win.RECT
+	if !win.GetClientRect(hwnd, &rect) {
+		return rect, false
+	}
+	topLeft := win.POINT{X: rect.Left, Y: rect.Top}
+	bottomRight := win.POINT{X: rect.Right, Y: rect.Bottom}
+	if !win.ClientToScreen(hwnd, &topLeft) || !win.ClientToScreen(hwnd, &bottomRight) {
+		return rect, false
+	}
+	return win.RECT{Left: topLeft.X, Top: topLeft.Y, Right: bottomRight.X, Bottom: bottomRight.Y}, true
+}
+
+// confineCursor confines the cursor to a client's client area, or moves the confinement along with it
+func confineCursor(hwnd windows.HWND) {
+	rect, ok := clientScreenRect(hwnd)
+	if !ok || rect.Right <= rect.Left || rect.Bottom <= rect.Top {
+		releaseCursor("no client area")
+		return
+	}
+	if confinedHWND == hwnd && confinedRect == rect {
+		return
+	}
+	err := clipCursor(&rect)
+	if err != nil {
+		slog.Error("Failed to confine cursor", append(windowAttrs(hwnd), "error", err)...)
+		return
+	}
+	if confinedHWND != hwnd {
+		slog.Info("Confined cursor", append(windowAttrs(hwnd), "rect", rectString(rect))...)
+	} else {
+		slog.Debug("Moved cursor confinement", append(windowAttrs(hwnd), "rect", rectString(rect))...)
+	}
+	confinedHWND = hwnd
+	confinedRect = rect
+}
+
+// releaseCursor frees the cursor if Shindow confined it
+func releaseCursor(reason string) {
+	if confinedHWND == 0 {
+		return
+	}
+	err := clipCursor(nil)
+	if err != nil {
+		slog.Error("Failed to release cursor", "reason", reason, "error", err)
+	}
+	slog.Info("Released cursor", append(windowAttrs(confinedHWND), "reason", reason)...)
+	confinedHWND = 0
+	confinedRect = win.RECT{}
+}
+
+// armCursor confines the cursor to hwnd if it is the foreground client and wants it
+func armCursor(hwnd windows.HWND) {
+	if isCursorReleased || isMoveSizing || windows.GetForegroundWindow() != hwnd || win.IsIconic(hwnd) || !isCursorConfined(hwnd) {
+		if hwnd == confinedHWND {
+			releaseCursor("not confined")
+		}
+		return
+	}
+	confineCursor(hwnd)
+}
+
+// toggleCursorRelease frees the cursor until the hotkey is pressed again
+func toggleCursorRelease() {
+	isCursorReleased = !isCursorReleased
+	if isCursorReleased {
+		releaseCursor("hotkey")
+		return
+	}
+	slog.Info("Cursor confinement re-enabled by hotkey")
+	armCursor(windows.GetForegroundWindow())
+}
+
+// onCursorEvent keeps the confinement in step with focus, alt-tab, moves and minimizing
+func onCursorEvent(event uint32, hwnd windows.HWND) {
+	switch event {
+	case eventSystemForeground:
+		if hwnd != confinedHWND {
+			releaseCursor("focus lost")
+		}
+		armCursor(hwnd)
+	case eventSystemSwitchStart:
+		releaseCursor("alt-tab")
+	case eventSystemMoveSizeStart:
+		if hwnd == confinedHWND {
+			isMoveSizing = true
+			releaseCursor("moving")
+		}
+	case eventSystemMoveSizeEnd:
+		isMoveSizing = false
+		armCursor(hwnd)
+	case eventSystemMinimizeStart:
+		if hwnd == confinedHWND {
+			releaseCursor("minimized")
+		}
+	case eventObjectLocationChange:
+		// re-arm on the new rect once the window moves, e.g. when a layout is applied
+		if hwnd == confinedHWND || (confinedHWND == 0 && hwnd == windows.GetForegroundWindow()) {
+			armCursor(hwnd)
+		}
+	}
+}
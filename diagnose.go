@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/xackery/shindow/winerr"
+	"github.com/xackery/shindow/winstyle"
+	"github.com/xackery/wlk/cpl"
+	"github.com/xackery/wlk/walk"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const attachParentProcess = ^uintptr(0) // ATTACH_PARENT_PROCESS
+
+var (
+	kernel32            = windows.NewLazySystemDLL("kernel32.dll")
+	attachConsoleProc   = kernel32.NewProc("AttachConsole")
+	getDpiForWindowProc = user32.NewProc("GetDpiForWindow")
+)
+
+// processDiagnostics is everything Shindow can see about a client process
+type processDiagnostics struct {
+	Version          string               `json:"version"`
+	PID              int                  `json:"pid"`
+	Name             string               `json:"name"`
+	Exe              string               `json:"exe"`
+	Integrity        string               `json:"integrity"` // empty if the process can't be opened
+	IsElevated       bool                 `json:"elevated"`
+	ShindowIntegrity string               `json:"shindow_integrity"` // windows of a higher level can't be changed
+	Windows          []*windowDiagnostics `json:"windows"`
+}
+
+// windowDiagnostics is everything Shindow can see about one window
+type windowDiagnostics struct {
+	HWND         string         `json:"hwnd"`
+	Class        string         `json:"class"`
+	Title        string         `json:"title"`
+	Style        string         `json:"style"`
+	StyleFlags   []string       `json:"style_flags"`
+	ExStyle      string         `json:"ex_style"`
+	ExStyleFlags []string       `json:"ex_style_flags"`
+	IsVisible    bool           `json:"visible"`
+	IsMinimized  bool           `json:"minimized"`
+	IsMaximized  bool           `json:"maximized"`
+	IsManaged    bool           `json:"managed"`
+	DriftCount   int            `json:"drift_count"`
+	WindowRect   diagnosticRect `json:"window_rect"`
+	ClientRect   diagnosticRect `json:"client_rect"`
+	Monitor      int            `json:"monitor"`
+	MonitorRect  diagnosticRect `json:"monitor_rect"`
+	WorkRect     diagnosticRect `json:"work_rect"`
+	DPI          uint32         `json:"dpi"`
+	LastError    string         `json:"last_error,omitempty"`
+
+	style   uint32
+	exStyle uint32
+}
+
+// diagnosticRect is a rect with its size spelled out
+type diagnosticRect struct {
+	Left   int32 `json:"left"`
+	Top    int32 `json:"top"`
+	Right  int32 `json:"right"`
+	Bottom int32 `json:"bottom"`
+	Width  int32 `json:"width"`
+	Height int32 `json:"height"`
+}
+
+func newDiagnosticRect(rect win.RECT) diagnosticRect {
+	return diagnosticRect{
+		Left:   rect.Left,
+		Top:    rect.Top,
+		Right:  rect.Right,
+		Bottom: rect.Bottom,
+		Width:  rect.Right - rect.Left,
+		Height: rect.Bottom - rect.Top,
+	}
+}
+
+func (r diagnosticRect) String() string {
+	return fmt.Sprintf("%d,%d %dx%d", r.Left, r.Top, r.Width, r.Height)
+}
+
+// diagnoseProcess inspects every top level window of a process
+func diagnoseProcess(pid int) (*processDiagnostics, error) {
+	d := &processDiagnostics{
+		Version: Version,
+		PID:     pid,
+		Exe:     processExe(pid),
+	}
+	if d.Exe != "" {
+		d.Name = d.Exe[strings.LastIndexAny(d.Exe, `\/`)+1:]
+	}
+	level, isElevated, err := processIntegrity(pid)
+	if err == nil {
+		d.Integrity = integrityName(level)
+		d.IsElevated = isElevated
+	}
+	own, _ := ownIntegrity()
+	d.ShindowIntegrity = integrityName(own)
+
+	var hwnds []windows.HWND
+	enumWindows(func(h uintptr) bool {
+		if pidByHWND(h) == uint32(pid) {
+			hwnds = append(hwnds, windows.HWND(h))
+		}
+		return true
+	})
+	if len(hwnds) == 0 {
+		return nil, winerr.New(winerr.ErrNoWindow, fmt.Sprintf("diagnose pid %d", pid), nil)
+	}
+
+	for _, hwnd := range hwnds {
+		d.Windows = append(d.Windows, diagnoseWindow(hwnd))
+	}
+	return d, nil
+}
+
+// diagnoseWindow inspects a single window, recording the last Win32 call that failed
+func diagnoseWindow(hwnd windows.HWND) *windowDiagnostics {
+	d := &windowDiagnostics{
+		HWND:        fmt.Sprintf("0x%X", hwnd),
+		Title:       windowText(hwnd),
+		IsVisible:   win.IsWindowVisible(hwnd),
+		IsMinimized: win.IsIconic(hwnd),
+		IsMaximized: win.IsZoomed(hwnd),
+	}
+	// the last error is per thread, so the goroutine must not move between a call failing and reading it
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	fail := func(call string) {
+		d.LastError = fmt.Sprintf("%s: %s", call, lastErrorMessage())
+	}
+
+	var class [256]uint16
+	_, err := windows.GetClassName(hwnd, &class[0], int32(len(class)))
+	if err != nil {
+		d.LastError = fmt.Sprintf("GetClassName: %s", err)
+	}
+	d.Class = syscall.UTF16ToString(class[:])
+
+	d.style = uint32(win.GetWindowLong(hwnd, win.GWL_STYLE))
+	d.Style = fmt.Sprintf("0x%08X", d.style)
+	d.StyleFlags = winstyle.Names(winstyle.Styles, d.style)
+	d.exStyle = uint32(win.GetWindowLong(hwnd, win.GWL_EXSTYLE))
+	d.ExStyle = fmt.Sprintf("0x%08X", d.exStyle)
+	d.ExStyleFlags = winstyle.Names(winstyle.ExStyles, d.exStyle)
+
+	var rect win.RECT
+	if !win.GetWindowRect(hwnd, &rect) {
+		fail("GetWindowRect")
+	}
+	d.WindowRect = newDiagnosticRect(rect)
+	rect = win.RECT{}
+	if !win.GetClientRect(hwnd, &rect) {
+		fail("GetClientRect")
+	}
+	d.ClientRect = newDiagnosticRect(rect)
+
+	hmon := win.MonitorFromWindow(hwnd, win.MONITOR_DEFAULTTONEAREST)
+	d.Monitor = monitorNumber(hmon)
+	info, ok := monitorInfo(hmon)
+	if !ok {
+		fail("GetMonitorInfo")
+	}
+	d.MonitorRect = newDiagnosticRect(info.RcMonitor)
+	d.WorkRect = newDiagnosticRect(info.RcWork)
+
+	// GetDpiForWindow is only available on Windows 10 1607 and later
+	if getDpiForWindowProc.Find() == nil {
+		dpi, _, _ := getDpiForWindowProc.Call(uintptr(hwnd))
+		d.DPI = uint32(dpi)
+	}
+
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	if ok {
+		d.IsManaged = true
+		d.DriftCount = client.DriftCount
+	}
+	managedMu.Unlock()
+	return d
+}
+
+// Text returns a human readable report
+func (d *processDiagnostics) Text() string {
+	out := fmt.Sprintf("Shindow v%s diagnostics for %s (pid %d)\n", d.Version, d.Name, d.PID)
+	out += fmt.Sprintf("exe: %s\n", d.Exe)
+	out += fmt.Sprintf("integrity: %s elevated=%t (shindow: %s)\n", d.Integrity, d.IsElevated, d.ShindowIntegrity)
+	for _, w := range d.Windows {
+		out += fmt.Sprintf("\nwindow %s\n", w.HWND)
+		out += fmt.Sprintf("  class:       %s\n", w.Class)
+		out += fmt.Sprintf("  title:       %s\n", w.Title)
+		out += fmt.Sprintf("  style:       %s %s\n", w.Style, winstyle.FormatStyle(w.style))
+		out += fmt.Sprintf("  ex-style:    %s %s\n", w.ExStyle, winstyle.FormatExStyle(w.exStyle))
+		out += fmt.Sprintf("  state:       visible=%t minimized=%t maximized=%t managed=%t drifts=%d\n", w.IsVisible, w.IsMinimized, w.IsMaximized, w.IsManaged, w.DriftCount)
+		out += fmt.Sprintf("  window rect: %s\n", w.WindowRect)
+		out += fmt.Sprintf("  client rect: %s\n", w.ClientRect)
+		out += fmt.Sprintf("  monitor:     %d at %s, work area %s\n", w.Monitor, w.MonitorRect, w.WorkRect)
+		out += fmt.Sprintf("  dpi:         %d\n", w.DPI)
+		if w.LastError != "" {
+			out += fmt.Sprintf("  last error:  %s\n", w.LastError)
+		}
+	}
+	return out
+}
+
+// JSON returns an indented JSON report
+func (d *processDiagnostics) JSON() (string, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+	return string(data), nil
+}
+
+// runDiagnose handles shindow diagnose [--pid N] [--json]
+func runDiagnose(args []string) error {
+	flags := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	pid := flags.Int("pid", 0, "process to diagnose, 0 for every EverQuest client")
+	isJSON := flags.Bool("json", false, "write JSON instead of text")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	pids := []int{*pid}
+	if *pid == 0 {
+		processes, err := listProcesses()
+		if err != nil {
+			return fmt.Errorf("list processes: %w", err)
+		}
+		if len(processes) == 0 {
+			return fmt.Errorf("no EverQuest clients are running")
+		}
+		pids = pids[:0]
+		for _, process := range processes {
+			pids = append(pids, process.PID)
+		}
+	}
+
+	for _, pid := range pids {
+		d, err := diagnoseProcess(pid)
+		if err != nil {
+			return err
+		}
+		out := d.Text()
+		if *isJSON {
+			out, err = d.JSON()
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Println(out)
+	}
+	return nil
+}
+
+// attachConsole connects stdout to the console shindow was started from, since it is built as a GUI app.
+// Output that is already redirected, e.g. to a file, is left alone
+func attachConsole() {
+	_, err := os.Stdout.Stat()
+	if err == nil {
+		return
+	}
+	attachConsoleProc.Call(attachParentProcess)
+	con, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	os.Stdout = con
+	os.Stderr = con
+}
+
+// showInspector opens a window with the diagnostics of a client
+func showInspector(pid int) error {
+	d, err := diagnoseProcess(pid)
+	if err != nil {
+		return err
+	}
+	text := d.Text()
+	jsonText, err := d.JSON()
+	if err != nil {
+		return err
+	}
+
+	var dlg *walk.Dialog
+	_, err = cpl.Dialog{
+		AssignTo: &dlg,
+		Title:    fmt.Sprintf("Inspector: %s (%d)", d.Name, d.PID),
+		MinSize:  cpl.Size{Width: 600, Height: 450},
+		Layout:   cpl.VBox{},
+		Children: []cpl.Widget{
+			cpl.TextEdit{
+				Text:     strings.ReplaceAll(text, "\n", "\r\n"),
+				ReadOnly: true,
+				VScroll:  true,
+				Font:     cpl.Font{Family: "Consolas", PointSize: 9},
+			},
+			cpl.Composite{
+				Layout: cpl.HBox{},
+				Children: []cpl.Widget{
+					cpl.PushButton{
+						Text: "Copy Text",
+						OnClicked: func() {
+							walk.Clipboard().SetText(text)
+						},
+					},
+					cpl.PushButton{
+						Text: "Copy JSON",
+						OnClicked: func() {
+							walk.Clipboard().SetText(jsonText)
+						},
+					},
+					cpl.PushButton{
+						Text: "Close",
+						OnClicked: func() {
+							dlg.Cancel()
+						},
+					},
+				},
+			},
+		},
+	}.Run(settingsWnd)
+	if err != nil {
+		return fmt.Errorf("run inspector: %w", err)
+	}
+	return nil
+}
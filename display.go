@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/xackery/shindow/fit"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	displayClassName = "ShindowDisplay"
+
+	dbtDevNodesChanged = 0x0007
+	dbtConfigChanged   = 0x0018
+
+	// displayChangeDelay lets a dock, hot-plug or mode change settle, since Windows sends a burst of
+	// notifications and moves windows around itself while it rearranges the desktop
+	displayChangeDelay = 1500 * time.Millisecond
+)
+
+var (
+	// displayTimer debounces display notifications. It is only touched on the event thread
+	displayTimer *time.Timer
+
+	displayMu sync.Mutex
+	// displayTopology is the monitor rects last handled, so notifications that changed nothing are ignored
+	displayTopology string
+
+	displayWndProcPtr = syscall.NewCallback(func(hwnd windows.HWND, msg uint32, wParam uintptr, lParam uintptr) uintptr {
+		switch msg {
+		case win.WM_DISPLAYCHANGE:
+			scheduleDisplayChange("display change")
+		case win.WM_DEVICECHANGE:
+			// docking changes the hardware configuration, and plugging a monitor in adds a device node
+			if wParam == dbtDevNodesChanged || wParam == dbtConfigChanged {
+				scheduleDisplayChange("device change")
+			}
+		}
+		return win.DefWindowProc(hwnd, msg, wParam, lParam)
+	})
+)
+
+// createDisplayWindow creates the hidden window display and device change notifications are sent to.
+// They are only broadcast to top level windows, so it can't be a message only window
+func createDisplayWindow() (windows.HWND, error) {
+	class := &win.WNDCLASSEX{
+		LpfnWndProc:   displayWndProcPtr,
+		HInstance:     win.GetModuleHandle(nil),
+		LpszClassName: StringToUTF16Ptr(displayClassName),
+	}
+	class.CbSize = uint32(unsafe.Sizeof(*class))
+	if win.RegisterClassEx(class) == 0 {
+		return 0, fmt.Errorf("RegisterClassEx: %w", syscall.GetLastError())
+	}
+	hwnd := win.CreateWindowEx(win.WS_EX_TOOLWINDOW, StringToUTF16Ptr(displayClassName),
+		StringToUTF16Ptr("Shindow Display"), win.WS_POPUP, 0, 0, 0, 0, 0, 0, win.GetModuleHandle(nil), nil)
+	if hwnd == 0 {
+		return 0, fmt.Errorf("CreateWindowEx: %w", syscall.GetLastError())
+	}
+
+	displayMu.Lock()
+	displayTopology = topology(monitorRects())
+	displayMu.Unlock()
+	return hwnd, nil
+}
+
+// scheduleDisplayChange handles the monitors changing once notifications stop arriving. Drift is not checked
+// until then, since Windows moves windows itself while it rearranges the desktop and reapplying their old
+// rects would undo the layout reapplied for the new monitors
+func scheduleDisplayChange(reason string) {
+	slog.Debug("Display notification", "reason", reason)
+	suspendDrift()
+	if displayTimer != nil {
+		displayTimer.Reset(displayChangeDelay)
+		return
+	}
+	displayTimer = time.AfterFunc(displayChangeDelay, func() {
+		settingsWnd.Synchronize(onDisplayChange)
+	})
+}
+
+// topology describes the monitor rects in enumeration order
+func topology(monitors []fit.Rect) string {
+	rects := make([]string, len(monitors))
+	for i, rect := range monitors {
+		rects[i] = rect.String()
+	}
+	return strings.Join(rects, "; ")
+}
+
+// onDisplayChange reapplies the layout that fits the new monitors, resolving its slots that span monitors again,
+// then brings back managed windows left off-screen and resumes drift checks. It must be called on the GUI thread
+func onDisplayChange() {
+	defer resumeDrift()
+	monitors := monitorRects()
+	current := topology(monitors)
+	displayMu.Lock()
+	previous := displayTopology
+	displayTopology = current
+	displayMu.Unlock()
+	if current == previous {
+		return
+	}
+	slog.Info("Monitors changed", "monitors", len(monitors), "from", previous, "to", current)
+
+	layout := cfg.LayoutForMonitors(monitors, activeLayout)
+	if layout != nil {
+		slog.Info("Reapplying layout", "layout", layout.Name)
+		err := reapplyLayout(layout, monitors)
+		if err != nil {
+			slog.Error("Failed to reapply layout", "layout", layout.Name, "error", err)
+		}
+	}
+
+	for _, hwnd := range managedWindows() {
+		err := bringIntoView(hwnd, monitors)
+		if err != nil {
+			slog.Error("Failed to bring window into view", append(windowAttrs(hwnd), "error", err)...)
+		}
+	}
+
+	pruneBackdrops()
+	updateBackdrops()
+}
+
+// bringIntoView moves a managed window that is mostly off-screen into the work area of its nearest monitor,
+// shrinking it if it no longer fits. Windows overflowing a little, such as slots widened for bezels, are left alone
+func bringIntoView(hwnd windows.HWND, monitors []fit.Rect) error {
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	var placement *Placement
+	if ok && !client.isHidden {
+		placement = client.placement()
+	}
+	managedMu.Unlock()
+	// minimized windows report an off-screen rect and are put back by restoreWindow
+	if placement == nil || win.IsIconic(hwnd) || !win.IsWindowVisible(hwnd) {
+		return nil
+	}
+
+	var current win.RECT
+	if !win.GetWindowRect(hwnd, &current) {
+		return fmt.Errorf("GetWindowRect: %w", syscall.GetLastError())
+	}
+	rect := fitRect(current)
+	visible := 0
+	for _, monitor := range monitors {
+		area := fit.Intersect(rect, monitor)
+		visible += area.W * area.H
+	}
+	if visible*2 >= rect.W*rect.H {
+		return nil
+	}
+
+	info, ok := monitorInfo(win.MonitorFromWindow(hwnd, win.MONITOR_DEFAULTTONEAREST))
+	if !ok {
+		return fmt.Errorf("GetMonitorInfo: %w", syscall.GetLastError())
+	}
+	placement.Rect = windowRect(fit.Clamp(rect, fitRect(info.RcWork)))
+	slog.Info("Bringing window into view", append(windowAttrs(hwnd), "from", rectString(current), "to", rectString(placement.Rect))...)
+	return ApplyPlacements([]*Placement{placement})
+}
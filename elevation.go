@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"unsafe"
+
+	"github.com/xackery/shindow/winerr"
+	"golang.org/x/sys/windows"
+)
+
+// mandatory integrity levels, the last sub-authority of a token's integrity SID
+const (
+	integrityUntrusted  = 0x0000
+	integrityLow        = 0x1000
+	integrityMedium     = 0x2000
+	integrityMediumPlus = 0x2100
+	integrityHigh       = 0x3000
+	integritySystem     = 0x4000
+	integrityProtected  = 0x5000
+)
+
+// integrityName returns the name Windows uses for an integrity level
+func integrityName(level uint32) string {
+	switch {
+	case level >= integrityProtected:
+		return "protected"
+	case level >= integritySystem:
+		return "system"
+	case level >= integrityHigh:
+		return "high"
+	case level >= integrityMediumPlus:
+		return "medium plus"
+	case level >= integrityMedium:
+		return "medium"
+	case level >= integrityLow:
+		return "low"
+	}
+	return "untrusted"
+}
+
+// processIntegrity returns the integrity level of a process and whether its token is elevated
+func processIntegrity(pid int) (uint32, bool, error) {
+	proc, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return 0, false, fmt.Errorf("OpenProcess %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(proc)
+
+	var token windows.Token
+	err = windows.OpenProcessToken(proc, windows.TOKEN_QUERY, &token)
+	if err != nil {
+		return 0, false, fmt.Errorf("OpenProcessToken %d: %w", pid, err)
+	}
+	defer token.Close()
+	return tokenIntegrity(token)
+}
+
+// tokenIntegrity returns the integrity level of a token and whether it is elevated
+func tokenIntegrity(token windows.Token) (uint32, bool, error) {
+	var n uint32
+	windows.GetTokenInformation(token, windows.TokenIntegrityLevel, nil, 0, &n)
+	if n == 0 {
+		return 0, false, fmt.Errorf("GetTokenInformation size: %w", windows.GetLastError())
+	}
+	buf := make([]byte, n)
+	err := windows.GetTokenInformation(token, windows.TokenIntegrityLevel, &buf[0], n, &n)
+	if err != nil {
+		return 0, false, fmt.Errorf("GetTokenInformation: %w", err)
+	}
+	label := (*windows.Tokenmandatorylabel)(unsafe.Pointer(&buf[0]))
+	sid := label.Label.Sid
+	level := sid.SubAuthority(uint32(sid.SubAuthorityCount()) - 1)
+	return level, token.IsElevated(), nil
+}
+
+// ownIntegrity returns the integrity level of Shindow and whether it runs elevated
+func ownIntegrity() (uint32, bool) {
+	level, isElevated, err := tokenIntegrity(windows.GetCurrentProcessToken())
+	if err != nil {
+		// assume the default for a desktop app
+		return integrityMedium, false
+	}
+	return level, isElevated
+}
+
+// checkAccess returns ErrAccessDenied if a window belongs to a process Shindow can't change,
+// because it runs at a higher integrity level and UIPI blocks SetWindowLong and SetWindowPos
+func checkAccess(hwnd windows.HWND) error {
+	pid := int(pidByHWND(uintptr(hwnd)))
+	level, _, err := processIntegrity(pid)
+	if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		return winerr.New(winerr.ErrAccessDenied, fmt.Sprintf("open pid %d", pid), err)
+	}
+	if err != nil {
+		// let the window operation report its own error
+		slog.Debug("Failed to read integrity level", append(windowAttrs(hwnd), "error", err)...)
+		return nil
+	}
+	own, _ := ownIntegrity()
+	if level <= own {
+		return nil
+	}
+	return winerr.New(winerr.ErrAccessDenied, fmt.Sprintf("change window %d", hwnd),
+		fmt.Errorf("pid %d runs at %s integrity and Shindow at %s, so Windows blocks changes to its windows", pid, integrityName(level), integrityName(own)))
+}
+
+// relaunchElevated starts another Shindow as administrator with the same arguments.
+// The caller should exit once it returns without error
+func relaunchElevated() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("executable: %w", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getwd: %w", err)
+	}
+	verb, _ := windows.UTF16PtrFromString("runas")
+	file, _ := windows.UTF16PtrFromString(exe)
+	args, _ := windows.UTF16PtrFromString(windows.ComposeCommandLine(os.Args[1:]))
+	dir, _ := windows.UTF16PtrFromString(cwd)
+	err = windows.ShellExecute(0, verb, file, args, dir, windows.SW_SHOWNORMAL)
+	if err != nil {
+		return fmt.Errorf("ShellExecute runas: %w", err)
+	}
+	slog.Info("Relaunched elevated", "exe", exe)
+	return nil
+}
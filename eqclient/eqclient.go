@@ -0,0 +1,178 @@
+// Package eqclient reads and writes the video mode keys of an EverQuest eqclient.ini
+package eqclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FileName is the name of the client config beside eqgame.exe
+	FileName = "eqclient.ini"
+
+	videoModeSection = "VideoMode"
+	keyWidth         = "WindowedWidth"
+	keyHeight        = "WindowedHeight"
+	keyXOffset       = "WindowedModeXOffset"
+	keyYOffset       = "WindowedModeYOffset"
+)
+
+// File is an eqclient.ini. Lines are kept as is so unrelated settings and comments survive a save
+type File struct {
+	path    string
+	lines   []string
+	newline string
+}
+
+// Resolution is the windowed video mode a client is configured for
+type Resolution struct {
+	X int
+	Y int
+	W int
+	H int
+}
+
+// PathForExe returns the eqclient.ini beside an eqgame.exe
+func PathForExe(exe string) string {
+	return filepath.Join(filepath.Dir(exe), FileName)
+}
+
+// Load reads an eqclient.ini
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", FileName, err)
+	}
+	f := &File{path: path, newline: "\n"}
+	text := string(data)
+	if strings.Contains(text, "\r\n") {
+		f.newline = "\r\n"
+	}
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if text != "" {
+		f.lines = strings.Split(text, "\n")
+	}
+	return f, nil
+}
+
+// Save writes the file back to where it was loaded from
+func (f *File) Save() error {
+	out := strings.Join(f.lines, f.newline) + f.newline
+	err := os.WriteFile(f.path, []byte(out), 0644)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", FileName, err)
+	}
+	return nil
+}
+
+// Resolution returns the configured windowed resolution and offset
+func (f *File) Resolution() (*Resolution, error) {
+	r := &Resolution{}
+	keys := []struct {
+		name     string
+		value    *int
+		required bool
+	}{
+		{keyWidth, &r.W, true},
+		{keyHeight, &r.H, true},
+		{keyXOffset, &r.X, false},
+		{keyYOffset, &r.Y, false},
+	}
+	for _, key := range keys {
+		value, ok := f.Get(videoModeSection, key.name)
+		if !ok {
+			if key.required {
+				return nil, fmt.Errorf("%s has no %s", FileName, key.name)
+			}
+			continue
+		}
+		val, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", key.name, err)
+		}
+		*key.value = val
+	}
+	return r, nil
+}
+
+// SetResolution sets the windowed resolution and offset
+func (f *File) SetResolution(r Resolution) {
+	f.Set(videoModeSection, keyWidth, strconv.Itoa(r.W))
+	f.Set(videoModeSection, keyHeight, strconv.Itoa(r.H))
+	f.Set(videoModeSection, keyXOffset, strconv.Itoa(r.X))
+	f.Set(videoModeSection, keyYOffset, strconv.Itoa(r.Y))
+}
+
+// Mismatch describes every way the configured resolution differs from want, or returns nil if it matches
+func (r *Resolution) Mismatch(want Resolution) []string {
+	var diffs []string
+	if r.W != want.W || r.H != want.H {
+		diffs = append(diffs, fmt.Sprintf("%s is %dx%d, layout is %dx%d", FileName, r.W, r.H, want.W, want.H))
+	}
+	if r.X != want.X || r.Y != want.Y {
+		diffs = append(diffs, fmt.Sprintf("%s offset is %d,%d, layout is %d,%d", FileName, r.X, r.Y, want.X, want.Y))
+	}
+	return diffs
+}
+
+// Get returns the value of a key in a section. Sections and keys are case insensitive
+func (f *File) Get(section string, key string) (string, bool) {
+	start, end := f.sectionRange(section)
+	if start == -1 {
+		return "", false
+	}
+	for _, line := range f.lines[start+1 : end] {
+		k, v, ok := strings.Cut(line, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of a key in a section, adding the key or section if missing
+func (f *File) Set(section string, key string, value string) {
+	line := key + "=" + value
+	start, end := f.sectionRange(section)
+	if start == -1 {
+		if len(f.lines) > 0 {
+			f.lines = append(f.lines, "")
+		}
+		f.lines = append(f.lines, "["+section+"]", line)
+		return
+	}
+	for i := start + 1; i < end; i++ {
+		k, _, ok := strings.Cut(f.lines[i], "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			f.lines[i] = line
+			return
+		}
+	}
+	// insert after the last non blank line of the section
+	insert := end
+	for insert > start+1 && strings.TrimSpace(f.lines[insert-1]) == "" {
+		insert--
+	}
+	f.lines = append(f.lines[:insert], append([]string{line}, f.lines[insert:]...)...)
+}
+
+// sectionRange returns the header line of a section and the line its keys end before, or -1 if missing
+func (f *File) sectionRange(section string) (int, int) {
+	start := -1
+	for i, line := range f.lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		if start != -1 {
+			return start, i
+		}
+		if strings.EqualFold(strings.TrimSpace(line[1:len(line)-1]), section) {
+			start = i
+		}
+	}
+	return start, len(f.lines)
+}
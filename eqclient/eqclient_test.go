@@ -0,0 +1,182 @@
+package eqclient
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// loadText writes text to an eqclient.ini and loads it
+func loadText(t *testing.T, text string) *File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	err := os.WriteFile(path, []byte(text), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestSetResolution(t *testing.T) {
+	resolution := Resolution{X: 10, Y: 20, W: 1280, H: 720}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "replaces existing keys",
+			text: "[VideoMode]\nWindowedWidth=800\nWindowedHeight=600\nWindowedModeXOffset=0\nWindowedModeYOffset=0\n",
+			want: "[VideoMode]\nWindowedWidth=1280\nWindowedHeight=720\nWindowedModeXOffset=10\nWindowedModeYOffset=20\n",
+		},
+		{
+			name: "keeps crlf",
+			text: "[Defaults]\r\nSound=1\r\n[VideoMode]\r\nWindowedWidth=800\r\nWindowedHeight=600\r\n",
+			want: "[Defaults]\r\nSound=1\r\n[VideoMode]\r\nWindowedWidth=1280\r\nWindowedHeight=720\r\nWindowedModeXOffset=10\r\nWindowedModeYOffset=20\r\n",
+		},
+		{
+			name: "inserts before trailing blank lines",
+			text: "[VideoMode]\nWindowedWidth=800\n\n\n[Options]\nMusic=0\n",
+			want: "[VideoMode]\nWindowedWidth=1280\nWindowedHeight=720\nWindowedModeXOffset=10\nWindowedModeYOffset=20\n\n\n[Options]\nMusic=0\n",
+		},
+		{
+			name: "case insensitive keys and section",
+			text: "[videomode]\nwindowedwidth = 800\nWINDOWEDHEIGHT=600\n",
+			want: "[videomode]\nWindowedWidth=1280\nWindowedHeight=720\nWindowedModeXOffset=10\nWindowedModeYOffset=20\n",
+		},
+		{
+			name: "appends a missing section",
+			text: "[Defaults]\nSound=1\n",
+			want: "[Defaults]\nSound=1\n\n[VideoMode]\nWindowedWidth=1280\nWindowedHeight=720\nWindowedModeXOffset=10\nWindowedModeYOffset=20\n",
+		},
+		{
+			name: "empty file",
+			text: "",
+			want: "[VideoMode]\nWindowedWidth=1280\nWindowedHeight=720\nWindowedModeXOffset=10\nWindowedModeYOffset=20\n",
+		},
+		{
+			name: "unrelated lines and comments survive",
+			text: "; written by the launcher\n[Defaults]\nSound=1\n  Odd Line Without Equals\n[VideoMode]\n; windowed size\nWindowedWidth=800\nFullscreen=0\n[Options]\nMusic=0\n",
+			want: "; written by the launcher\n[Defaults]\nSound=1\n  Odd Line Without Equals\n[VideoMode]\n; windowed size\nWindowedWidth=1280\nFullscreen=0\nWindowedHeight=720\nWindowedModeXOffset=10\nWindowedModeYOffset=20\n[Options]\nMusic=0\n",
+		},
+		{
+			name: "keys in other sections are left alone",
+			text: "[Options]\nWindowedWidth=640\n[VideoMode]\nWindowedWidth=800\n",
+			want: "[Options]\nWindowedWidth=640\n[VideoMode]\nWindowedWidth=1280\nWindowedHeight=720\nWindowedModeXOffset=10\nWindowedModeYOffset=20\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := loadText(t, tt.text)
+			f.SetResolution(resolution)
+			err := f.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(f.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("saved %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolution(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    *Resolution
+		wantErr bool
+	}{
+		{
+			name: "every key",
+			text: "[VideoMode]\nWindowedWidth=1280\nWindowedHeight=720\nWindowedModeXOffset=-1280\nWindowedModeYOffset=20\n",
+			want: &Resolution{X: -1280, Y: 20, W: 1280, H: 720},
+		},
+		{
+			name: "offset defaults to 0",
+			text: "[VideoMode]\nWindowedWidth=1280\nWindowedHeight=720\n",
+			want: &Resolution{W: 1280, H: 720},
+		},
+		{
+			name: "case insensitive with spaces",
+			text: "[ VIDEOMODE ]\r\nwindowedwidth = 1280\r\nwindowedheight= 720\r\n",
+			want: &Resolution{W: 1280, H: 720},
+		},
+		{
+			name:    "missing height",
+			text:    "[VideoMode]\nWindowedWidth=1280\n",
+			wantErr: true,
+		},
+		{
+			name:    "key in another section",
+			text:    "[Options]\nWindowedWidth=1280\nWindowedHeight=720\n[VideoMode]\n",
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			text:    "[VideoMode]\nWindowedWidth=wide\nWindowedHeight=720\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing section",
+			text:    "[Defaults]\nSound=1\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadText(t, tt.text).Resolution()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Resolution() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolution() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSectionRange(t *testing.T) {
+	f := &File{lines: []string{"; comment", "[Defaults]", "Sound=1", "", "[VideoMode]", "WindowedWidth=800", "[Options]", "Music=0"}}
+	tests := []struct {
+		section   string
+		wantStart int
+		wantEnd   int
+	}{
+		{"Defaults", 1, 4},
+		{"videomode", 4, 6},
+		{"Options", 6, 8},
+		{"Missing", -1, 8},
+	}
+	for _, tt := range tests {
+		start, end := f.sectionRange(tt.section)
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("sectionRange(%q) = %d, %d, want %d, %d", tt.section, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	r := &Resolution{X: 0, Y: 0, W: 1280, H: 720}
+	if diffs := r.Mismatch(Resolution{W: 1280, H: 720}); diffs != nil {
+		t.Errorf("Mismatch of the same resolution = %q", diffs)
+	}
+	if diffs := r.Mismatch(Resolution{X: 1280, W: 1920, H: 1080}); len(diffs) != 2 {
+		t.Errorf("Mismatch of another size and offset = %q, want 2 differences", diffs)
+	}
+}
@@ -0,0 +1,145 @@
+// Package fit resolves the rect a client is placed into within a slot, keeping its aspect ratio if asked to
+package fit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mode is how a client is sized to a slot
+type Mode string
+
+const (
+	// Stretch fills the slot, ignoring the aspect ratio
+	Stretch Mode = "stretch"
+	// Aspect is the largest rect of the aspect ratio that fits in the slot, centered with bars on the sides left over
+	Aspect Mode = "aspect"
+	// Fill is the smallest rect of the aspect ratio that covers the slot, centered and overflowing it
+	Fill Mode = "fill"
+	// Integer is the base resolution scaled by the largest whole number that fits in the slot, centered
+	Integer Mode = "integer"
+)
+
+// Rect is an area in screen pixels
+type Rect struct {
+	X int
+	Y int
+	W int
+	H int
+}
+
+func (r Rect) String() string {
+	return fmt.Sprintf("%d,%d %dx%d", r.X, r.Y, r.W, r.H)
+}
+
+// Size is an aspect ratio, e.g. 16:9, or a base resolution, e.g. 1920x1080
+type Size struct {
+	W int
+	H int
+}
+
+// IsZero returns true if the size is unset
+func (s Size) IsZero() bool {
+	return s.W <= 0 || s.H <= 0
+}
+
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.W, s.H)
+}
+
+// ParseMode parses a mode name, empty is Stretch
+func ParseMode(value string) (Mode, error) {
+	mode := Mode(strings.ToLower(strings.TrimSpace(value)))
+	switch mode {
+	case "":
+		return Stretch, nil
+	case Stretch, Aspect, Fill, Integer:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown fit mode %s, expected stretch, aspect, fill or integer", value)
+}
+
+// ParseSize parses a size in w:h or wxh format
+func ParseSize(value string) (Size, error) {
+	w, h, ok := strings.Cut(strings.ToLower(value), ":")
+	if !ok {
+		w, h, ok = strings.Cut(strings.ToLower(value), "x")
+	}
+	if !ok {
+		return Size{}, fmt.Errorf("expected w:h or wxh, got %s", value)
+	}
+	width, err := strconv.Atoi(strings.TrimSpace(w))
+	if err != nil {
+		return Size{}, fmt.Errorf("w: %w", err)
+	}
+	height, err := strconv.Atoi(strings.TrimSpace(h))
+	if err != nil {
+		return Size{}, fmt.Errorf("h: %w", err)
+	}
+	size := Size{W: width, H: height}
+	if size.IsZero() {
+		return Size{}, fmt.Errorf("width and height must be positive, got %s", value)
+	}
+	return size, nil
+}
+
+// Resolve returns the rect a client is placed into within area. size is the aspect ratio
+// for Aspect and Fill, and the base resolution for Integer. A zero size stretches
+func Resolve(area Rect, mode Mode, size Size) Rect {
+	if size.IsZero() || area.W <= 0 || area.H <= 0 {
+		return area
+	}
+	switch mode {
+	case Aspect:
+		// the area is narrower than the ratio when aw/ah <= sw/sh
+		if area.W*size.H <= area.H*size.W {
+			return center(area, area.W, area.W*size.H/size.W)
+		}
+		return center(area, area.H*size.W/size.H, area.H)
+	case Fill:
+		if area.W*size.H >= area.H*size.W {
+			return center(area, area.W, area.W*size.H/size.W)
+		}
+		return center(area, area.H*size.W/size.H, area.H)
+	case Integer:
+		scale := min(area.W/size.W, area.H/size.H)
+		if scale < 1 {
+			// the base resolution doesn't fit even once, so shrink it keeping its aspect ratio
+			return Resolve(area, Aspect, size)
+		}
+		return center(area, size.W*scale, size.H*scale)
+	}
+	return area
+}
+
+// center returns a w by h rect centered on area
+func center(area Rect, w int, h int) Rect {
+	return Rect{
+		X: area.X + (area.W-w)/2,
+		Y: area.Y + (area.H-h)/2,
+		W: w,
+		H: h,
+	}
+}
+
+// Intersect returns the area a and b share, or a zero rect if they don't overlap
+func Intersect(a Rect, b Rect) Rect {
+	x := max(a.X, b.X)
+	y := max(a.Y, b.Y)
+	right := min(a.X+a.W, b.X+b.W)
+	bottom := min(a.Y+a.H, b.Y+b.H)
+	if right <= x || bottom <= y {
+		return Rect{}
+	}
+	return Rect{X: x, Y: y, W: right - x, H: bottom - y}
+}
+
+// Clamp moves rect into bounds, shrinking it first if it is larger
+func Clamp(rect Rect, bounds Rect) Rect {
+	rect.W = min(rect.W, bounds.W)
+	rect.H = min(rect.H, bounds.H)
+	rect.X = max(bounds.X, min(rect.X, bounds.X+bounds.W-rect.W))
+	rect.Y = max(bounds.Y, min(rect.Y, bounds.Y+bounds.H-rect.H))
+	return rect
+}
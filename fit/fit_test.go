@@ -0,0 +1,127 @@
+package fit
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	ultrawide := Rect{X: 0, Y: 0, W: 2560, H: 1080}
+	fullHD := Rect{X: 1920, Y: 0, W: 1920, H: 1080}
+
+	tests := []struct {
+		name string
+		area Rect
+		mode Mode
+		size Size
+		want Rect
+	}{
+		{"stretch ignores the ratio", ultrawide, Stretch, Size{W: 16, H: 9}, ultrawide},
+		{"empty mode stretches", ultrawide, "", Size{W: 16, H: 9}, ultrawide},
+		{"16:9 centered on 21:9", ultrawide, Aspect, Size{W: 16, H: 9}, Rect{X: 320, Y: 0, W: 1920, H: 1080}},
+		{"16:9 in a square, bars above and below", Rect{X: 100, Y: 50, W: 1000, H: 1000}, Aspect, Size{W: 16, H: 9}, Rect{X: 100, Y: 269, W: 1000, H: 562}},
+		{"aspect matching the area", fullHD, Aspect, Size{W: 16, H: 9}, fullHD},
+		{"fill 16:9 over 21:9 overflows top and bottom", ultrawide, Fill, Size{W: 16, H: 9}, Rect{X: 0, Y: -180, W: 2560, H: 1440}},
+		{"fill 21:9 over 16:9 overflows the sides", fullHD, Fill, Size{W: 64, H: 27}, Rect{X: 1920 - 320, Y: 0, W: 2560, H: 1080}},
+		{"integer scales the base by 2", fullHD, Integer, Size{W: 640, H: 480}, Rect{X: 1920 + 320, Y: 60, W: 1280, H: 960}},
+		{"integer base that fits once", fullHD, Integer, Size{W: 1280, H: 720}, Rect{X: 1920 + 320, Y: 180, W: 1280, H: 720}},
+		{"integer base larger than the area shrinks to its ratio", Rect{X: 0, Y: 0, W: 1600, H: 1200}, Integer, Size{W: 3840, H: 2160}, Rect{X: 0, Y: 150, W: 1600, H: 900}},
+		{"zero size stretches", ultrawide, Aspect, Size{}, ultrawide},
+		{"zero size integer stretches", ultrawide, Integer, Size{}, ultrawide},
+		{"zero width area is left alone", Rect{X: 5, Y: 5, W: 0, H: 100}, Aspect, Size{W: 16, H: 9}, Rect{X: 5, Y: 5, W: 0, H: 100}},
+		{"zero height area is left alone", Rect{X: 5, Y: 5, W: 100, H: 0}, Fill, Size{W: 16, H: 9}, Rect{X: 5, Y: 5, W: 100, H: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(tt.area, tt.mode, tt.size)
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	monitor := Rect{X: 0, Y: 0, W: 1920, H: 1080}
+	tests := []struct {
+		name string
+		a    Rect
+		want Rect
+	}{
+		{"inside", Rect{X: 100, Y: 100, W: 800, H: 600}, Rect{X: 100, Y: 100, W: 800, H: 600}},
+		{"overlapping the right edge", Rect{X: 1820, Y: 0, W: 200, H: 100}, Rect{X: 1820, Y: 0, W: 100, H: 100}},
+		{"covering", Rect{X: -10, Y: -10, W: 2000, H: 2000}, monitor},
+		{"touching", Rect{X: 1920, Y: 0, W: 100, H: 100}, Rect{}},
+		{"apart", Rect{X: 3000, Y: 3000, W: 100, H: 100}, Rect{}},
+		{"zero sized", Rect{X: 10, Y: 10}, Rect{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Intersect(tt.a, monitor)
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if back := Intersect(monitor, tt.a); back != got {
+				t.Fatalf("not symmetric, got %v and %v", got, back)
+			}
+		})
+	}
+}
+
+func TestClamp(t *testing.T) {
+	work := Rect{X: 0, Y: 0, W: 1920, H: 1040}
+	tests := []struct {
+		name string
+		rect Rect
+		want Rect
+	}{
+		{"inside is left alone", Rect{X: 100, Y: 100, W: 800, H: 600}, Rect{X: 100, Y: 100, W: 800, H: 600}},
+		{"off the right", Rect{X: 3000, Y: 100, W: 800, H: 600}, Rect{X: 1120, Y: 100, W: 800, H: 600}},
+		{"off the top left", Rect{X: -2000, Y: -500, W: 800, H: 600}, Rect{X: 0, Y: 0, W: 800, H: 600}},
+		{"larger is shrunk", Rect{X: 1920, Y: 0, W: 2560, H: 1440}, work},
+		{"wider only", Rect{X: -100, Y: 200, W: 2560, H: 600}, Rect{X: 0, Y: 200, W: 1920, H: 600}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Clamp(tt.rect, work)
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Size
+		wantErr bool
+	}{
+		{value: "16:9", want: Size{W: 16, H: 9}},
+		{value: "1920x1080", want: Size{W: 1920, H: 1080}},
+		{value: " 640 X 480 ", want: Size{W: 640, H: 480}},
+		{value: "16", wantErr: true},
+		{value: "0:9", wantErr: true},
+		{value: "a:b", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q): error %v, want error %t", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q): got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for value, want := range map[string]Mode{"": Stretch, "Aspect": Aspect, " fill ": Fill, "integer": Integer} {
+		got, err := ParseMode(value)
+		if err != nil || got != want {
+			t.Errorf("ParseMode(%q): got %s, %v, want %s", value, got, err, want)
+		}
+	}
+	_, err := ParseMode("zoom")
+	if err == nil {
+		t.Errorf("ParseMode(zoom) succeeded")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/shindow/ipc"
+	"github.com/xackery/wlk/walk"
+	"golang.org/x/sys/windows"
+)
+
+const processSuspendResume = 0x0800 // PROCESS_SUSPEND_RESUME
+
+var (
+	ntdll                = windows.NewLazySystemDLL("ntdll.dll")
+	ntSuspendProcessProc = ntdll.NewProc("NtSuspendProcess")
+	ntResumeProcessProc  = ntdll.NewProc("NtResumeProcess")
+
+	chkFocusPolicies *walk.CheckBox
+
+	// focusPoliciesEnabled pauses every focus policy when false, guarded by cpuMu
+	focusPoliciesEnabled = true
+	// foregroundPID is the process that last took the foreground, guarded by cpuMu
+	foregroundPID int
+	// isForegroundMain is true while the main slot has focus, guarded by cpuMu
+	isForegroundMain bool
+)
+
+// suspendProcess suspends every thread of a process
+func suspendProcess(pid int) error {
+	proc, err := windows.OpenProcess(processSuspendResume, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("OpenProcess %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(proc)
+	status, _, _ := ntSuspendProcessProc.Call(uintptr(proc))
+	if status != 0 {
+		return fmt.Errorf("NtSuspendProcess: status 0x%X", status)
+	}
+	return nil
+}
+
+// resumeProcess resumes a process suspended by suspendProcess
+func resumeProcess(pid int) error {
+	proc, err := windows.OpenProcess(processSuspendResume, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("OpenProcess %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(proc)
+	status, _, _ := ntResumeProcessProc.Call(uintptr(proc))
+	if status != 0 {
+		return fmt.Errorf("NtResumeProcess: status 0x%X", status)
+	}
+	return nil
+}
+
+// onForeground puts every other client in the background while the main slot has focus,
+// and brings them back once it loses focus
+func onForeground(hwnd windows.HWND) {
+	pid := int(pidByHWND(uintptr(hwnd)))
+	slot := managedSlot(pid)
+
+	cpuMu.Lock()
+	defer cpuMu.Unlock()
+	_, isClient := cpuClients[pid]
+	foregroundPID = pid
+	isForegroundMain = isClient && (cfg.MainSlot == 0 || slot == cfg.MainSlot)
+	applyFocusPolicies()
+}
+
+// applyFocusPolicies moves each client in or out of the background for the current foreground process.
+// cpuMu must be held
+func applyFocusPolicies() {
+	for _, client := range cpuClients {
+		isBackground := focusPoliciesEnabled && isForegroundMain && client.PID != foregroundPID && client.CPU.HasBackground()
+		if isBackground == client.isBackground {
+			continue
+		}
+		client.setBackground(isBackground)
+	}
+}
+
+// setFocusPolicies pauses or resumes every focus policy. Paused clients get their settings back immediately
+func setFocusPolicies(isEnabled bool) {
+	cpuMu.Lock()
+	if focusPoliciesEnabled == isEnabled {
+		cpuMu.Unlock()
+		return
+	}
+	focusPoliciesEnabled = isEnabled
+	slog.Info("Switched focus policies", "enabled", isEnabled)
+	applyFocusPolicies()
+	cpuMu.Unlock()
+
+	if settingsWnd == nil {
+		return
+	}
+	settingsWnd.Synchronize(func() {
+		if chkFocusPolicies != nil && chkFocusPolicies.Checked() != isEnabled {
+			chkFocusPolicies.SetChecked(isEnabled)
+		}
+	})
+}
+
+// isFocusPoliciesEnabled returns false while focus policies are paused
+func isFocusPoliciesEnabled() bool {
+	cpuMu.Lock()
+	defer cpuMu.Unlock()
+	return focusPoliciesEnabled
+}
+
+// setBackground applies or reverts a client's background priority, affinity and suspension.
+// Every step is logged, and reverting undoes whatever was applied even if part of it failed. cpuMu must be held
+func (c *cpuClient) setBackground(isBackground bool) {
+	if isBackground {
+		c.enterBackground()
+	} else {
+		c.leaveBackground()
+	}
+	c.isBackground = isBackground
+}
+
+func (c *cpuClient) enterBackground() {
+	// affinity and priority are only changed if they can be read, so leaving the background can restore them
+	if c.CPU.BackgroundAffinity != 0 {
+		affinity, err := processAffinity(c.PID)
+		c.affinity = 0
+		if err != nil {
+			slog.Error("Failed to read affinity, not narrowing it", "pid", c.PID, "error", err)
+		} else {
+			c.affinity = affinity
+			err = setAffinity(c.PID, c.CPU.BackgroundAffinity)
+			if err != nil {
+				slog.Error("Failed to narrow affinity", "pid", c.PID, "affinity", config.FormatAffinity(c.CPU.BackgroundAffinity), "error", err)
+			} else {
+				slog.Info("Narrowed affinity", "pid", c.PID, "affinity", config.FormatAffinity(c.CPU.BackgroundAffinity))
+			}
+		}
+	}
+	if c.CPU.BackgroundPriority != "" {
+		priority, err := processPriority(c.PID)
+		c.priority = ""
+		if err != nil {
+			slog.Error("Failed to read priority, not dropping it", "pid", c.PID, "error", err)
+		} else {
+			c.priority = priority
+			err = setPriority(c.PID, c.CPU.BackgroundPriority)
+			if err != nil {
+				slog.Error("Failed to drop priority", "pid", c.PID, "priority", c.CPU.BackgroundPriority, "error", err)
+			} else {
+				slog.Info("Dropped priority", "pid", c.PID, "priority", c.CPU.BackgroundPriority)
+			}
+		}
+	}
+	c.scheduleSuspend()
+}
+
+// scheduleSuspend suspends the client once it has been in the background for SuspendAfter. cpuMu must be held
+func (c *cpuClient) scheduleSuspend() {
+	if c.CPU.SuspendAfter <= 0 {
+		return
+	}
+	if c.suspendTimer != nil {
+		c.suspendTimer.Stop()
+	}
+	c.suspendTimer = time.AfterFunc(c.CPU.SuspendAfter, func() {
+		cpuMu.Lock()
+		defer cpuMu.Unlock()
+		// the client may have come back or exited since the timer was set
+		if !c.isBackground || c.isSuspended || cpuClients[c.PID] != c {
+			return
+		}
+		err := suspendProcess(c.PID)
+		if err != nil {
+			slog.Error("Failed to suspend idle client", "pid", c.PID, "error", err)
+			return
+		}
+		slog.Info("Suspended idle client", "pid", c.PID, "after", c.CPU.SuspendAfter)
+		c.isSuspended = true
+	})
+}
+
+// wakeWindow resumes the client owning hwnd if it was suspended as an idle alt. Every window operation on a client
+// calls it first: SetWindowPos, SetWindowLong, ShowWindow and SetWindowText wait on the window's thread, and would
+// block the caller for as long as the process stays suspended. The client is suspended again after another
+// SuspendAfter if it is still in the background
+func wakeWindow(hwnd windows.HWND) {
+	pid := int(pidByHWND(uintptr(hwnd)))
+	cpuMu.Lock()
+	defer cpuMu.Unlock()
+	c, ok := cpuClients[pid]
+	if !ok || !c.isSuspended {
+		return
+	}
+	err := resumeProcess(pid)
+	if err != nil {
+		slog.Error("Failed to resume client for a window operation", "pid", pid, "error", err)
+		return
+	}
+	c.isSuspended = false
+	slog.Info("Resumed suspended client for a window operation", "pid", pid)
+	c.scheduleSuspend()
+}
+
+func (c *cpuClient) leaveBackground() {
+	if c.suspendTimer != nil {
+		c.suspendTimer.Stop()
+		c.suspendTimer = nil
+	}
+	if c.isSuspended {
+		err := resumeProcess(c.PID)
+		if err != nil {
+			slog.Error("Failed to resume client", "pid", c.PID, "error", err)
+		} else {
+			slog.Info("Resumed client", "pid", c.PID)
+			c.isSuspended = false
+		}
+	}
+	if c.CPU.BackgroundAffinity != 0 {
+		affinity := c.CPU.Affinity
+		if affinity == 0 {
+			affinity = c.affinity
+		}
+		if affinity != 0 {
+			err := setAffinity(c.PID, affinity)
+			if err != nil {
+				slog.Error("Failed to restore affinity", "pid", c.PID, "affinity", config.FormatAffinity(affinity), "error", err)
+			} else {
+				slog.Info("Restored affinity", "pid", c.PID, "affinity", config.FormatAffinity(affinity))
+			}
+		}
+	}
+	if c.CPU.BackgroundPriority != "" {
+		priority := c.CPU.Priority
+		if priority == "" {
+			priority = c.priority
+		}
+		if priority != "" {
+			err := setPriority(c.PID, priority)
+			if err != nil {
+				slog.Error("Failed to restore priority", "pid", c.PID, "priority", priority, "error", err)
+			} else {
+				slog.Info("Restored priority", "pid", c.PID, "priority", priority)
+			}
+		}
+	}
+}
+
+// restoreCPU takes every client out of the background, e.g. when Shindow exits
+func restoreCPU() {
+	cpuMu.Lock()
+	defer cpuMu.Unlock()
+	for _, client := range cpuClients {
+		if client.isBackground || client.isSuspended {
+			client.setBackground(false)
+		}
+	}
+}
+
+// runFocus pauses or resumes the focus policies of a running Shindow over ipc
+func runFocus(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: shindow focus on|off")
+	}
+	var isEnabled bool
+	switch args[0] {
+	case "on":
+		isEnabled = true
+	case "off":
+	default:
+		return fmt.Errorf("usage: shindow focus on|off, got %s", args[0])
+	}
+	c, err := config.LoadCastConfig(filepath.Dir(os.Args[0]) + "/shindow.ini")
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if c.IPCAddress == "" || c.IPCToken == "" {
+		return fmt.Errorf("ipc_address is not set in shindow.ini, or Shindow hasn't run with it yet to create ipc_token")
+	}
+	err = ipc.NewClient(c.IPCAddress, c.IPCToken).SetFocusPolicies(isEnabled)
+	if err != nil {
+		return fmt.Errorf("set focus policies: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/shindow/eqclient"
+	"github.com/xackery/wlk/walk"
+	"github.com/xackery/wlk/win"
+)
+
+// rectResolution converts a window rect to an eqclient.ini resolution
+func rectResolution(rect win.RECT) eqclient.Resolution {
+	return eqclient.Resolution{
+		X: int(rect.Left),
+		Y: int(rect.Top),
+		W: int(rect.Right - rect.Left),
+		H: int(rect.Bottom - rect.Top),
+	}
+}
+
+// syncProfileEQClient compares a profile's eqclient.ini with its layout slot before launch,
+// writing the slot into it if the profile asks for that
+func syncProfileEQClient(profile *config.Profile) error {
+	slot, err := cfg.SlotRect(profile, monitorRects())
+	if err != nil {
+		return err
+	}
+	if slot == nil {
+		return nil
+	}
+
+	dir := profile.Dir
+	if dir == "" {
+		dir = filepath.Dir(profile.Exe)
+	}
+	ini, err := eqclient.Load(filepath.Join(dir, eqclient.FileName))
+	if err != nil {
+		return fmt.Errorf("profile %s: %w", profile.Name, err)
+	}
+
+	want := rectResolution(slotRect(slot))
+	if profile.WriteEQClient {
+		ini.SetResolution(want)
+		err = ini.Save()
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", profile.Name, err)
+		}
+		return nil
+	}
+
+	current, err := ini.Resolution()
+	if err != nil {
+		slog.Warn("Failed to read eqclient.ini resolution", "profile", profile.Name, "error", err)
+		return nil
+	}
+	for _, diff := range current.Mismatch(want) {
+		slog.Warn("eqclient.ini does not match layout", "profile", profile.Name, "mismatch", diff)
+	}
+	return nil
+}
+
+// checkEQClient compares the selected client's eqclient.ini with the resolution fields and offers to fix it
+func checkEQClient(pid int) error {
+	exe := processExe(pid)
+	if exe == "" {
+		return fmt.Errorf("failed to find exe for pid %d", pid)
+	}
+	ini, err := eqclient.Load(eqclient.PathForExe(exe))
+	if err != nil {
+		return err
+	}
+	rect, err := resolutionRect()
+	if err != nil {
+		return fmt.Errorf("resolution: %w", err)
+	}
+	want := rectResolution(rect)
+
+	current, err := ini.Resolution()
+	var diffs []string
+	if err != nil {
+		diffs = append(diffs, err.Error())
+	} else {
+		diffs = current.Mismatch(want)
+	}
+	if len(diffs) == 0 {
+		walk.MsgBox(nil, "eqclient.ini", "eqclient.ini matches the Shindow resolution", walk.MsgBoxOK)
+		return nil
+	}
+
+	msg := strings.Join(diffs, "\n") + fmt.Sprintf("\n\nWrite %dx%d at %d,%d to %s?\nEverQuest reads it on the next launch.", want.W, want.H, want.X, want.Y, eqclient.PathForExe(exe))
+	if walk.MsgBox(nil, "eqclient.ini", msg, walk.MsgBoxYesNo) != walk.DlgCmdYes {
+		return nil
+	}
+	ini.SetResolution(want)
+	return ini.Save()
+}
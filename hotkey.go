@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/shindow/ipc"
+)
+
+var (
+	registerHotKeyProc   = user32.NewProc("RegisterHotKey")
+	unregisterHotKeyProc = user32.NewProc("UnregisterHotKey")
+)
+
+// hotkey is a global key combination whose action runs on the event thread
+type hotkey struct {
+	Name   string // config key, for logging
+	Value  string // as written in shindow.ini, empty if disabled
+	Action func()
+}
+
+// hotkeys returns every configured hotkey
+func hotkeys() []*hotkey {
+	return []*hotkey{
+		{Name: "cursor_hotkey", Value: cfg.CursorHotkey, Action: toggleCursorRelease},
+		{Name: "broadcast_hotkey", Value: cfg.BroadcastHotkey, Action: toggleBroadcast},
+		{Name: "minimize_hotkey", Value: cfg.MinimizeHotkey, Action: runWindowState(ipc.WindowStateMinimize)},
+		{Name: "restore_hotkey", Value: cfg.RestoreHotkey, Action: runWindowState(ipc.WindowStateRestore)},
+		{Name: "hide_hotkey", Value: cfg.HideHotkey, Action: runWindowState(ipc.WindowStateHide)},
+	}
+}
+
+// registerHotkeys registers hotkeys for the calling thread, their WM_HOTKEY id is their index + 1.
+// Hotkeys that fail to register, e.g. because another program owns them, are logged and skipped
+func registerHotkeys(hotkeys []*hotkey) []uintptr {
+	var ids []uintptr
+	for i, h := range hotkeys {
+		key, err := config.ParseHotkey(h.Value)
+		if err != nil {
+			slog.Error("Failed to parse hotkey", "name", h.Name, "hotkey", h.Value, "error", err)
+			continue
+		}
+		if key.IsZero() {
+			continue
+		}
+		id := uintptr(i + 1)
+		ret, _, err := registerHotKeyProc.Call(0, id, uintptr(key.Modifiers|config.ModNoRepeat), uintptr(key.Key))
+		if ret == 0 {
+			slog.Error("Failed to register hotkey", "name", h.Name, "hotkey", h.Value, "error", err)
+			continue
+		}
+		slog.Debug("Registered hotkey", "name", h.Name, "hotkey", h.Value)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// unregisterHotkeys removes hotkeys registered by registerHotkeys on the calling thread
+func unregisterHotkeys(ids []uintptr) {
+	for _, id := range ids {
+		unregisterHotKeyProc.Call(0, id)
+	}
+}
+
+// onHotkey runs the action of the hotkey with the given WM_HOTKEY id
+func onHotkey(hotkeys []*hotkey, id uintptr) {
+	if id < 1 || int(id) > len(hotkeys) {
+		return
+	}
+	h := hotkeys[id-1]
+	slog.Debug("Pressed hotkey", "name", h.Name, "hotkey", h.Value)
+	h.Action()
+}
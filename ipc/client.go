@@ -0,0 +1,130 @@
+package ipc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/xackery/shindow/winerr"
+)
+
+// Client drives a running Shindow over its IPC endpoint
+type Client struct {
+	addr  string
+	token string
+	http  *http.Client
+}
+
+// NewClient returns a client for a Shindow listening on addr, e.g. 127.0.0.1:7341, authenticating with its ipc_token
+func NewClient(addr string, token string) *Client {
+	return &Client{
+		addr:  addr,
+		token: token,
+		http:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Clients lists the running EverQuest clients
+func (c *Client) Clients() ([]*ClientInfo, error) {
+	var clients []*ClientInfo
+	err := c.do(http.MethodGet, "/clients", nil, &clients)
+	if err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// Status returns the state of the Shindow instance
+func (c *Client) Status() (*Status, error) {
+	status := &Status{}
+	err := c.do(http.MethodGet, "/status", nil, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// ApplyRect moves a client's window to rect without changing its style
+func (c *Client) ApplyRect(pid int, rect Rect) error {
+	return c.do(http.MethodPost, "/rect", &RectRequest{PID: pid, Rect: rect}, nil)
+}
+
+// ToggleBorderless strips or restores a client's frame. rect is used only when made borderless
+func (c *Client) ToggleBorderless(pid int, isBorderless bool, rect Rect) error {
+	return c.do(http.MethodPost, "/borderless", &BorderlessRequest{PID: pid, IsBorderless: isBorderless, Rect: rect}, nil)
+}
+
+// ApplyLayout places every client into the named layout
+func (c *Client) ApplyLayout(name string) error {
+	return c.do(http.MethodPost, "/layout", &LayoutRequest{Name: name}, nil)
+}
+
+// SetFocusPolicies pauses or resumes the focus policies, restoring every client when paused
+func (c *Client) SetFocusPolicies(isEnabled bool) error {
+	return c.do(http.MethodPost, "/focus", &FocusRequest{IsEnabled: isEnabled}, nil)
+}
+
+// SetWindowState minimizes, restores or hides every managed client, see WindowStateMinimize
+func (c *Client) SetWindowState(state string) error {
+	return c.do(http.MethodPost, "/windows", &WindowStateRequest{State: state}, nil)
+}
+
+func (c *Client) do(method string, path string, body interface{}, out interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&buf).Encode(body)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", path, err)
+		}
+	}
+
+	req, err := http.NewRequest(method, "http://"+c.addr+path, &buf)
+	if err != nil {
+		return fmt.Errorf("new request %s: %w", path, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errResp := &errorResponse{}
+		err = json.NewDecoder(resp.Body).Decode(errResp)
+		if err != nil || errResp.Error == "" {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return &remoteError{
+			msg:  fmt.Sprintf("%s %s: %s", method, path, errResp.Error),
+			kind: winerr.Lookup(errResp.Kind),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// remoteError is an error returned by the server. It matches its winerr kind with errors.Is
+type remoteError struct {
+	msg  string
+	kind error
+}
+
+func (e *remoteError) Error() string {
+	return e.msg
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,343 @@
+// Package ipc exposes Shindow's window operations over a loopback HTTP/JSON endpoint
+package ipc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xackery/shindow/winerr"
+)
+
+// Controller performs the operations requested over IPC, using the same core functions as the GUI
+type Controller interface {
+	Clients() ([]*ClientInfo, error)
+	ApplyRect(pid int, rect Rect) error
+	ToggleBorderless(pid int, isBorderless bool, rect Rect) error
+	ApplyLayout(name string) error
+	Status() (*Status, error)
+	SetFocusPolicies(isEnabled bool) error
+	SetWindowState(state string) error
+}
+
+// window states accepted by POST /windows
+const (
+	WindowStateMinimize = "minimize" // minimize every managed client
+	WindowStateRestore  = "restore"  // restore every managed client into its rect and style
+	WindowStateHide     = "hide"     // hide every managed client from the screen and taskbar
+)
+
+// Rect is a window rect in screen coordinates
+type Rect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// ClientInfo describes a running EverQuest client
+type ClientInfo struct {
+	PID          int    `json:"pid"`
+	Name         string `json:"name"`
+	HWND         uint64 `json:"hwnd"`
+	Rect         Rect   `json:"rect"`
+	IsBorderless bool   `json:"borderless"`
+}
+
+// Status describes the running Shindow instance
+type Status struct {
+	Version       string   `json:"version"`
+	Clients       int      `json:"clients"`
+	Layouts       []string `json:"layouts"`
+	FocusPolicies bool     `json:"focus_policies"` // false while focus policies are paused
+}
+
+// RectRequest is the body of a POST /rect
+type RectRequest struct {
+	PID  int  `json:"pid"`
+	Rect Rect `json:"rect"`
+}
+
+// BorderlessRequest is the body of a POST /borderless
+type BorderlessRequest struct {
+	PID          int  `json:"pid"`
+	IsBorderless bool `json:"borderless"`
+	Rect         Rect `json:"rect"`
+}
+
+// LayoutRequest is the body of a POST /layout
+type LayoutRequest struct {
+	Name string `json:"name"`
+}
+
+// FocusRequest is the body of a POST /focus
+type FocusRequest struct {
+	IsEnabled bool `json:"enabled"`
+}
+
+// WindowStateRequest is the body of a POST /windows
+type WindowStateRequest struct {
+	State string `json:"state"`
+}
+
+// errorResponse is returned with any non-200 status. Window errors also carry their kind,
+// Win32 code and a hint, see winerr
+type errorResponse struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind,omitempty"`
+	Code  uint32 `json:"code,omitempty"`
+	Hint  string `json:"hint,omitempty"`
+}
+
+// Server serves a Controller on a loopback address
+type Server struct {
+	listener net.Listener
+	http     *http.Server
+}
+
+// Listen opens a loopback listener for the controller. Non-loopback addresses and an empty token are refused
+func Listen(addr string, token string, ctrl Controller) (*Server, error) {
+	if token == "" {
+		return nil, fmt.Errorf("ipc token is empty")
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("split %s: %w", addr, err)
+	}
+	ip := net.ParseIP(host)
+	if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("ipc address %s is not a loopback address", addr)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("split %s: %w", listener.Addr(), err)
+	}
+
+	s := &Server{
+		listener: listener,
+		http: &http.Server{
+			Handler:           NewHandler(ctrl, port, token),
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+	return s, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Serve handles requests until the server is closed
+func (s *Server) Serve() error {
+	err := s.http.Serve(s.listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.http.Shutdown(ctx)
+}
+
+// NewHandler returns the http handler routing requests to ctrl. Requests must carry token as a bearer token
+// and name a loopback host on port, so web pages can't reach the endpoint, see guard
+func NewHandler(ctrl Controller, port string, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/clients", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		clients, err := ctrl.Clients()
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, clients)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		status, err := ctrl.Status()
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, status)
+	})
+	mux.HandleFunc("/rect", func(w http.ResponseWriter, r *http.Request) {
+		req := &RectRequest{}
+		if !allowMethod(w, r, http.MethodPost) || !readJSON(w, r, req) {
+			return
+		}
+		if req.Rect.W <= 0 || req.Rect.H <= 0 {
+			writeError(w, http.StatusBadRequest, winerr.New(winerr.ErrInvalidRect, "check rect", fmt.Errorf("width and height must be positive, got %dx%d", req.Rect.W, req.Rect.H)))
+			return
+		}
+		err := ctrl.ApplyRect(req.PID, req.Rect)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, struct{}{})
+	})
+	mux.HandleFunc("/borderless", func(w http.ResponseWriter, r *http.Request) {
+		req := &BorderlessRequest{}
+		if !allowMethod(w, r, http.MethodPost) || !readJSON(w, r, req) {
+			return
+		}
+		if req.IsBorderless && (req.Rect.W <= 0 || req.Rect.H <= 0) {
+			writeError(w, http.StatusBadRequest, winerr.New(winerr.ErrInvalidRect, "check rect", fmt.Errorf("width and height must be positive, got %dx%d", req.Rect.W, req.Rect.H)))
+			return
+		}
+		err := ctrl.ToggleBorderless(req.PID, req.IsBorderless, req.Rect)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, struct{}{})
+	})
+	mux.HandleFunc("/layout", func(w http.ResponseWriter, r *http.Request) {
+		req := &LayoutRequest{}
+		if !allowMethod(w, r, http.MethodPost) || !readJSON(w, r, req) {
+			return
+		}
+		err := ctrl.ApplyLayout(req.Name)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, struct{}{})
+	})
+	mux.HandleFunc("/focus", func(w http.ResponseWriter, r *http.Request) {
+		req := &FocusRequest{}
+		if !allowMethod(w, r, http.MethodPost) || !readJSON(w, r, req) {
+			return
+		}
+		err := ctrl.SetFocusPolicies(req.IsEnabled)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, struct{}{})
+	})
+	mux.HandleFunc("/windows", func(w http.ResponseWriter, r *http.Request) {
+		req := &WindowStateRequest{}
+		if !allowMethod(w, r, http.MethodPost) || !readJSON(w, r, req) {
+			return
+		}
+		switch req.State {
+		case WindowStateMinimize, WindowStateRestore, WindowStateHide:
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown window state %q, expected minimize, restore or hide", req.State))
+			return
+		}
+		err := ctrl.SetWindowState(req.State)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, struct{}{})
+	})
+	return guard(mux, port, token)
+}
+
+// guard rejects requests a web page could make. A page can post text/plain cross origin without a preflight,
+// and DNS rebinding lets it reach loopback under its own host name, but it can't learn the token
+func guard(next http.Handler, port string, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host, port) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %s is not a loopback address on port %s", r.Host, port))
+			return
+		}
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or wrong token, see ipc_token in shindow.ini"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost returns true if a Host header names 127.0.0.1, ::1 or localhost on port
+func isLoopbackHost(host string, port string) bool {
+	name, hostPort, err := net.SplitHostPort(host)
+	if err != nil || hostPort != port {
+		return false
+	}
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be application/json, got %q", r.Header.Get("Content-Type")))
+		return false
+	}
+	err = json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&errorResponse{
+		Error: err.Error(),
+		Kind:  winerr.Name(err),
+		Code:  winerr.Code(err),
+		Hint:  winerr.Hint(err),
+	})
+}
+
+// errorStatus returns the http status for a controller error
+func errorStatus(err error) int {
+	switch winerr.Kind(err) {
+	case winerr.ErrNoWindow:
+		return http.StatusNotFound
+	case winerr.ErrMaximized:
+		return http.StatusConflict
+	case winerr.ErrAccessDenied:
+		return http.StatusForbidden
+	case winerr.ErrInvalidRect:
+		return http.StatusBadRequest
+	case winerr.ErrStyleRejected:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,271 @@
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xackery/shindow/winerr"
+)
+
+const testToken = "secret"
+
+// fakeController records the calls made to it and returns err from each
+type fakeController struct {
+	calls []string
+	err   error
+}
+
+func (f *fakeController) Clients() ([]*ClientInfo, error) {
+	f.calls = append(f.calls, "clients")
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []*ClientInfo{{PID: 42, Name: "eqgame.exe", HWND: 0x1234, Rect: Rect{X: 1, Y: 2, W: 3, H: 4}, IsBorderless: true}}, nil
+}
+
+func (f *fakeController) ApplyRect(pid int, rect Rect) error {
+	f.calls = append(f.calls, fmt.Sprintf("rect %d %v", pid, rect))
+	return f.err
+}
+
+func (f *fakeController) ToggleBorderless(pid int, isBorderless bool, rect Rect) error {
+	f.calls = append(f.calls, fmt.Sprintf("borderless %d %t %v", pid, isBorderless, rect))
+	return f.err
+}
+
+func (f *fakeController) ApplyLayout(name string) error {
+	f.calls = append(f.calls, "layout "+name)
+	return f.err
+}
+
+func (f *fakeController) Status() (*Status, error) {
+	f.calls = append(f.calls, "status")
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Status{Version: "1.2.3", Clients: 2, Layouts: []string{"trio"}, FocusPolicies: true}, nil
+}
+
+func (f *fakeController) SetFocusPolicies(isEnabled bool) error {
+	f.calls = append(f.calls, fmt.Sprintf("focus %t", isEnabled))
+	return f.err
+}
+
+func (f *fakeController) SetWindowState(state string) error {
+	f.calls = append(f.calls, "windows "+state)
+	return f.err
+}
+
+// newTestServer serves ctrl on a loopback port and returns a client for it
+func newTestServer(t *testing.T, ctrl Controller) (*httptest.Server, *Client) {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(nil)
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	ts.Config.Handler = NewHandler(ctrl, port, testToken)
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts, NewClient(ts.Listener.Addr().String(), testToken)
+}
+
+func TestRoutes(t *testing.T) {
+	ctrl := &fakeController{}
+	_, client := newTestServer(t, ctrl)
+
+	clients, err := client.Clients()
+	if err != nil {
+		t.Fatalf("clients: %v", err)
+	}
+	if len(clients) != 1 || clients[0].PID != 42 || clients[0].HWND != 0x1234 || !clients[0].IsBorderless {
+		t.Fatalf("clients: got %+v", clients[0])
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	want := &Status{Version: "1.2.3", Clients: 2, Layouts: []string{"trio"}, FocusPolicies: true}
+	if !reflect.DeepEqual(status, want) {
+		t.Fatalf("status: got %+v, want %+v", status, want)
+	}
+
+	rect := Rect{X: 10, Y: 20, W: 800, H: 600}
+	steps := []struct {
+		name string
+		fn   func() error
+	}{
+		{"rect", func() error { return client.ApplyRect(7, rect) }},
+		{"borderless on", func() error { return client.ToggleBorderless(7, true, rect) }},
+		{"borderless off", func() error { return client.ToggleBorderless(7, false, Rect{}) }},
+		{"layout", func() error { return client.ApplyLayout("trio") }},
+		{"focus", func() error { return client.SetFocusPolicies(false) }},
+		{"windows", func() error { return client.SetWindowState(WindowStateHide) }},
+	}
+	for _, step := range steps {
+		err := step.fn()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+
+	wantCalls := []string{
+		"clients",
+		"status",
+		"rect 7 {10 20 800 600}",
+		"borderless 7 true {10 20 800 600}",
+		"borderless 7 false {0 0 0 0}",
+		"layout trio",
+		"focus false",
+		"windows hide",
+	}
+	if !reflect.DeepEqual(ctrl.calls, wantCalls) {
+		t.Fatalf("calls: got %q, want %q", ctrl.calls, wantCalls)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		kind   error
+		status string
+	}{
+		{winerr.ErrNoWindow, "404"},
+		{winerr.ErrMaximized, "409"},
+		{winerr.ErrAccessDenied, "403"},
+		{winerr.ErrInvalidRect, "400"},
+		{winerr.ErrStyleRejected, "422"},
+	}
+	for _, tt := range tests {
+		t.Run(winerr.Name(tt.kind), func(t *testing.T) {
+			ctrl := &fakeController{err: winerr.New(tt.kind, "SetWindowPos", errors.New("boom"))}
+			ts, client := newTestServer(t, ctrl)
+
+			err := client.ApplyLayout("trio")
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("got %v, want kind %v", err, tt.kind)
+			}
+			if winerr.Lookup(winerr.Name(err)) != tt.kind {
+				t.Fatalf("lookup of %q did not return %v", winerr.Name(err), tt.kind)
+			}
+
+			resp := post(t, ts, "/layout", "application/json", testToken, `{"name":"trio"}`)
+			if !strings.HasPrefix(resp.Status, tt.status) {
+				t.Fatalf("status: got %s, want %s", resp.Status, tt.status)
+			}
+		})
+	}
+
+	t.Run("unclassified", func(t *testing.T) {
+		_, client := newTestServer(t, &fakeController{err: errors.New("boom")})
+		err := client.SetFocusPolicies(true)
+		if err == nil || winerr.Kind(err) != nil {
+			t.Fatalf("got %v, want an error of no kind", err)
+		}
+	})
+}
+
+func TestBadInput(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		host        string
+		contentType string
+		token       string
+		body        string
+		status      int
+	}{
+		{name: "wrong method", method: http.MethodGet, path: "/rect", status: http.StatusMethodNotAllowed},
+		{name: "post to get route", path: "/clients", body: `{}`, status: http.StatusMethodNotAllowed},
+		{name: "bad json", path: "/layout", body: `{"name":`, status: http.StatusBadRequest},
+		{name: "empty rect", path: "/rect", body: `{"pid":1,"rect":{"w":0,"h":600}}`, status: http.StatusBadRequest},
+		{name: "negative borderless rect", path: "/borderless", body: `{"pid":1,"borderless":true,"rect":{"w":-1,"h":600}}`, status: http.StatusBadRequest},
+		{name: "unknown window state", path: "/windows", body: `{"state":"explode"}`, status: http.StatusBadRequest},
+		{name: "text plain", path: "/windows", contentType: "text/plain", body: `{"state":"hide"}`, status: http.StatusUnsupportedMediaType},
+		{name: "form", path: "/focus", contentType: "application/x-www-form-urlencoded", body: `enabled=false`, status: http.StatusUnsupportedMediaType},
+		{name: "no content type", path: "/focus", contentType: "none", body: `{"enabled":false}`, status: http.StatusUnsupportedMediaType},
+		{name: "missing token", path: "/layout", token: "none", body: `{"name":"trio"}`, status: http.StatusUnauthorized},
+		{name: "wrong token", path: "/layout", token: "guess", body: `{"name":"trio"}`, status: http.StatusUnauthorized},
+		{name: "rebound host", path: "/layout", host: "evil.example", body: `{"name":"trio"}`, status: http.StatusForbidden},
+		{name: "wrong port", path: "/layout", host: "127.0.0.1:1", body: `{"name":"trio"}`, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := &fakeController{}
+			ts, _ := newTestServer(t, ctrl)
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req, err := http.NewRequest(method, ts.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("new request: %v", err)
+			}
+			switch tt.contentType {
+			case "":
+				req.Header.Set("Content-Type", "application/json")
+			case "none":
+			default:
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			switch tt.token {
+			case "":
+				req.Header.Set("Authorization", "Bearer "+testToken)
+			case "none":
+			default:
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("do: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status: got %d, want %d", resp.StatusCode, tt.status)
+			}
+			if len(ctrl.calls) > 0 {
+				t.Fatalf("controller was called: %q", ctrl.calls)
+			}
+		})
+	}
+}
+
+func TestListenRefusesUnsafe(t *testing.T) {
+	_, err := Listen("0.0.0.0:0", testToken, &fakeController{})
+	if err == nil {
+		t.Fatalf("listened on a non-loopback address")
+	}
+	_, err = Listen("127.0.0.1:0", "", &fakeController{})
+	if err == nil {
+		t.Fatalf("listened without a token")
+	}
+}
+
+// post sends a raw POST to the test server
+func post(t *testing.T, ts *httptest.Server, path string, contentType string, token string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/xackery/shindow/broadcast"
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	whKeyboardLL = 13
+	hcAction     = 0
+
+	llkhfExtended = 0x01
+	llkhfInjected = 0x10
+	llkhfUp       = 0x80
+
+	// broadcastQueueSize is how many keys can wait for the broadcast worker before new ones are dropped
+	broadcastQueueSize = 64
+)
+
+// queuedKey is a key waiting for the broadcast worker, with the window that had focus when it was pressed
+type queuedKey struct {
+	key        broadcast.Key
+	foreground windows.HWND
+}
+
+// kbdllHookStruct is a KBDLLHOOKSTRUCT
+type kbdllHookStruct struct {
+	VKCode      uint32
+	ScanCode    uint32
+	Flags       uint32
+	Time        uint32
+	DwExtraInfo uintptr
+}
+
+var (
+	setWindowsHookExProc    = user32.NewProc("SetWindowsHookExW")
+	unhookWindowsHookExProc = user32.NewProc("UnhookWindowsHookEx")
+	callNextHookExProc      = user32.NewProc("CallNextHookEx")
+	postMessageProc         = user32.NewProc("PostMessageW")
+
+	// broadcasting is only touched on the event thread, which the keyboard hook is delivered to
+	keyboardHook    uintptr
+	broadcastRouter *broadcast.Router
+	broadcastQueue  chan queuedKey
+	// droppedKeys counts keys the full queue turned away, reported by the worker rather than logged in the hook
+	droppedKeys atomic.Int32
+
+	// keyboardHookCallbackPtr holds up every key press on the system until it returns, and Windows silently drops
+	// hooks that take longer than LowLevelHooksTimeout. It only queues keys, the broadcast worker takes locks and posts them
+	keyboardHookCallbackPtr = syscall.NewCallback(func(nCode uintptr, wParam uintptr, lParam uintptr) uintptr {
+		if int32(nCode) == hcAction && broadcastRouter != nil {
+			info := *(**kbdllHookStruct)(unsafe.Pointer(&lParam))
+			key := broadcast.Key{
+				VK:         info.VKCode,
+				Scan:       info.ScanCode,
+				IsUp:       info.Flags&llkhfUp != 0,
+				IsExtended: info.Flags&llkhfExtended != 0,
+			}
+			// keys sent by other tools are left alone so two broadcasters can't echo each other
+			if info.Flags&llkhfInjected == 0 && broadcastRouter.IsMirrored(key) {
+				select {
+				case broadcastQueue <- queuedKey{key: key, foreground: windows.GetForegroundWindow()}:
+				default:
+					droppedKeys.Add(1)
+				}
+			}
+		}
+		ret, _, _ := callNextHookExProc.Call(0, nCode, wParam, lParam)
+		return ret
+	})
+)
+
+// postMessageSender delivers broadcast keys by posting key messages to the target's window
+type postMessageSender struct{}
+
+// Send posts a WM_KEYDOWN or WM_KEYUP for key to hwnd
+func (postMessageSender) Send(hwnd uintptr, key broadcast.Key) error {
+	msg := uintptr(win.WM_KEYDOWN)
+	if key.IsUp {
+		msg = win.WM_KEYUP
+	}
+	ret, _, err := postMessageProc.Call(hwnd, msg, uintptr(key.VK), key.LParam())
+	if ret == 0 {
+		return fmt.Errorf("PostMessage: %w", err)
+	}
+	return nil
+}
+
+// newBroadcastRouter returns a router for the configured keys and groups
+func newBroadcastRouter() (*broadcast.Router, error) {
+	keys, err := config.ParseKeys(cfg.BroadcastKeys)
+	if err != nil {
+		return nil, fmt.Errorf("parse broadcast_keys: %w", err)
+	}
+	var groups []*config.Group
+	for _, name := range cfg.BroadcastGroupNames() {
+		group := cfg.Group(name)
+		if group == nil {
+			return nil, fmt.Errorf("group %s not found", name)
+		}
+		groups = append(groups, group)
+	}
+	var target func(broadcast.Client) bool
+	if len(groups) > 0 {
+		target = func(client broadcast.Client) bool {
+			for _, group := range groups {
+				if group.Matches(client.Slot, client.Profile) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return broadcast.NewRouter(keys, target, postMessageSender{}), nil
+}
+
+// toggleBroadcast starts or stops mirroring keys from the main slot to the broadcast groups
+func toggleBroadcast() {
+	if keyboardHook != 0 {
+		stopBroadcast()
+		return
+	}
+	router, err := newBroadcastRouter()
+	if err != nil {
+		slog.Error("Failed to start broadcasting", "error", err)
+		return
+	}
+	if len(router.Keys) == 0 {
+		slog.Warn("Not broadcasting, broadcast_keys is empty")
+		return
+	}
+	hook, _, err := setWindowsHookExProc.Call(whKeyboardLL, keyboardHookCallbackPtr, uintptr(win.GetModuleHandle(nil)), 0)
+	if hook == 0 {
+		slog.Error("Failed to hook keyboard", "error", err)
+		return
+	}
+	keyboardHook = hook
+	broadcastRouter = router
+	broadcastQueue = make(chan queuedKey, broadcastQueueSize)
+	go broadcastWorker(router, broadcastQueue)
+	slog.Info("Started broadcasting", "keys", cfg.BroadcastKeys, "groups", cfg.BroadcastGroups)
+}
+
+// stopBroadcast removes the keyboard hook if broadcasting
+func stopBroadcast() {
+	if keyboardHook == 0 {
+		return
+	}
+	unhookWindowsHookExProc.Call(keyboardHook)
+	keyboardHook = 0
+	// the hook runs on this thread, so nothing sends on the queue once it is removed
+	broadcastRouter.Stop()
+	close(broadcastQueue)
+	broadcastRouter = nil
+	broadcastQueue = nil
+	slog.Info("Stopped broadcasting")
+}
+
+// broadcastWorker mirrors queued keys in the order they were pressed until the queue is closed
+func broadcastWorker(router *broadcast.Router, queue chan queuedKey) {
+	for queued := range queue {
+		broadcastKey(router, queued.key, queued.foreground)
+		if dropped := droppedKeys.Swap(0); dropped > 0 {
+			slog.Warn("Dropped broadcast keys, the clients are not keeping up", "keys", dropped)
+		}
+	}
+}
+
+// broadcastKey mirrors a key to the targets if the main slot had focus when it was pressed
+func broadcastKey(router *broadcast.Router, key broadcast.Key, foreground windows.HWND) {
+	source, clients, ok := broadcastClients(foreground)
+	if !ok {
+		return
+	}
+	targets, err := router.Route(source, clients, key)
+	if err != nil {
+		slog.Warn("Failed to broadcast key", "vk", fmt.Sprintf("0x%02X", key.VK), "error", err)
+		return
+	}
+	if len(targets) > 0 {
+		slog.Debug("Broadcast key", "vk", fmt.Sprintf("0x%02X", key.VK), "up", key.IsUp, "targets", len(targets))
+	}
+}
+
+// broadcastClients returns the foreground client and every managed client, or false unless
+// the foreground window is the main slot, or any managed client if no main slot is set
+func broadcastClients(foreground windows.HWND) (broadcast.Client, []broadcast.Client, bool) {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	var source broadcast.Client
+	isSource := false
+	var clients []broadcast.Client
+	for hwnd, managed := range managedClients {
+		client := broadcast.Client{HWND: uintptr(hwnd), Slot: managed.Slot}
+		if managed.Profile != nil {
+			client.Profile = managed.Profile.Name
+		}
+		if hwnd == foreground && (cfg.MainSlot == 0 || managed.Slot == cfg.MainSlot) {
+			source = client
+			isSource = true
+		}
+		clients = append(clients, client)
+	}
+	return source, clients, isSource
+}
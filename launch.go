@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	launchWindowTimeout = 90 * time.Second
+	launchPollInterval  = 250 * time.Millisecond
+	// launchSettleDelay lets the client finish creating its window before it is placed
+	launchSettleDelay = time.Second
+)
+
+// launchedClient is a started profile and the window it opened
+type launchedClient struct {
+	profile *config.Profile
+	pid     int
+	hwnd    windows.HWND
+}
+
+// startProfile starts a profile's exe and waits for its main window
+func startProfile(profile *config.Profile) (*launchedClient, error) {
+	if profile.Exe == "" {
+		return nil, fmt.Errorf("profile %s has no exe", profile.Name)
+	}
+
+	err := syncProfileEQClient(profile)
+	if err != nil {
+		slog.Warn("Failed to sync eqclient.ini", "profile", profile.Name, "error", err)
+	}
+
+	cmd := exec.Command(profile.Exe)
+	cmd.Dir = profile.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = filepath.Dir(profile.Exe)
+	}
+	cmd.Env = append(os.Environ(), profile.Env...)
+	// args are passed through untouched so they match what the batch files used
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: syscall.EscapeArg(profile.Exe) + " " + profile.Args}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("start %s: %w", profile.Exe, err)
+	}
+	pid := cmd.Process.Pid
+	go cmd.Wait()
+
+	slog.Info("Launched profile", "profile", profile.Name, "pid", pid, "exe", profile.Exe, "args", profile.Args)
+
+	hwnd, err := waitForWindow(pid, launchWindowTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", profile.Name, err)
+	}
+	tuneClient(pid, hwnd, profile)
+	return &launchedClient{profile: profile, pid: pid, hwnd: hwnd}, nil
+}
+
+// waitForWindow polls until a process shows its main window
+func waitForWindow(pid int, timeout time.Duration) (windows.HWND, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		hwnd := mainWindowByPID(pid)
+		if hwnd != 0 {
+			time.Sleep(launchSettleDelay)
+			return hwnd, nil
+		}
+		time.Sleep(launchPollInterval)
+	}
+	return 0, fmt.Errorf("pid %d did not open a window within %s", pid, timeout)
+}
+
+// launchProfiles starts every profile, then places all of their windows in one transaction
+func launchProfiles(profiles []*config.Profile) error {
+	if len(profiles) == 0 {
+		return fmt.Errorf("no profile selected")
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	var clients []*launchedClient
+	for _, profile := range profiles {
+		wg.Add(1)
+		go func(profile *config.Profile) {
+			defer wg.Done()
+			client, err := startProfile(profile)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			clients = append(clients, client)
+		}(profile)
+	}
+	wg.Wait()
+
+	var placements []*Placement
+	monitors := monitorRects()
+	for _, client := range clients {
+		slot, err := cfg.SlotRect(client.profile, monitors)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if slot == nil {
+			if client.profile.IsBorderless {
+				errs = append(errs, fmt.Errorf("profile %s: borderless needs a layout slot", client.profile.Name))
+			}
+			continue
+		}
+		placements = append(placements, &Placement{
+			HWND:         client.hwnd,
+			Rect:         slotRect(slot),
+			IsBorderless: client.profile.IsBorderless,
+			Recipe:       windowRecipe(client.hwnd, client.profile.Recipe),
+			IsBackdrop:   cfg.Layout(client.profile.Layout).IsBackdrop,
+			Profile:      client.profile,
+			Slot:         client.profile.Slot,
+		})
+	}
+
+	err := ApplyPlacements(placements)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("place windows: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// launchProfilesAsync launches profiles without blocking the GUI and reports the result when done
+func launchProfilesAsync(profiles []*config.Profile) {
+	go func() {
+		err := launchProfiles(profiles)
+		processes, listErr := listProcesses()
+		settingsWnd.Synchronize(func() {
+			if listErr == nil {
+				setProcesses(processes)
+			}
+			if err != nil {
+				errorBox("Failed to launch", err)
+			}
+		})
+	}()
+}
+
+// profileNames returns the names of every configured profile
+func profileNames() []string {
+	names := []string{}
+	for _, profile := range cfg.Profiles {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+// mainWindowByPID returns the first visible, unowned top level window of a process
+func mainWindowByPID(pid int) windows.HWND {
+	var hwnd windows.HWND
+	enumWindows(func(h uintptr) bool {
+		if pidByHWND(h) != uint32(pid) {
+			return true
+		}
+		if !win.IsWindowVisible(windows.HWND(h)) || win.GetWindow(windows.HWND(h), win.GW_OWNER) != 0 {
+			return true
+		}
+		hwnd = windows.HWND(h)
+		return false
+	})
+	return hwnd
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/shindow/fit"
+	"github.com/xackery/wlk/win"
+)
+
+// activeLayout is the name of the layout last applied, reapplied when the monitors change. It is only touched on the GUI thread
+var activeLayout string
+
+// applyLayout places clients into the layout's slots in the order given, committing every move at once.
+// A pid of 0 or a client whose window can't be found keeps its slot empty, and if the commit fails every placed client failed
+func applyLayout(layout *config.Layout, pids []int) ([]*clientResult, error) {
+	if layout == nil {
+		return nil, fmt.Errorf("no layout selected")
+	}
+
+	warnSpans(layout)
+	monitors := monitorRects()
+	var results []*clientResult
+	var placed []*clientResult
+	var placements []*Placement
+	for i, pid := range pids {
+		if i >= len(layout.Slots) {
+			break
+		}
+		if pid == 0 {
+			continue
+		}
+		result := &clientResult{PID: pid}
+		results = append(results, result)
+		slot, err := layout.PlacedSlot(i, monitors)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		hwnd, err := hwndByPID(pid)
+		if err != nil {
+			result.Err = fmt.Errorf("slot %d: %w", i+1, err)
+			continue
+		}
+		placed = append(placed, result)
+		placements = append(placements, &Placement{
+			HWND:         hwnd,
+			Rect:         slotRect(slot),
+			IsBorderless: true,
+			IsBackdrop:   layout.IsBackdrop,
+			Slot:         i + 1,
+		})
+	}
+
+	err := ApplyPlacements(placements)
+	if err != nil {
+		err = fmt.Errorf("layout %s: %w", layout.Name, err)
+		for _, result := range placed {
+			result.Err = err
+		}
+		return results, nil
+	}
+	if len(placements) > 0 {
+		activeLayout = layout.Name
+	}
+	return results, nil
+}
+
+// reapplyLayout moves the clients placed into slots to where the layout puts those slots on the given monitors.
+// Only their rects are resolved again, so each keeps the borderless setting, recipe and profile it was placed with
+func reapplyLayout(layout *config.Layout, monitors []fit.Rect) error {
+	warnSpans(layout)
+	var errs []error
+	var placements []*Placement
+	for _, placement := range slotPlacements() {
+		if placement.Slot > len(layout.Slots) {
+			continue
+		}
+		slot, err := layout.PlacedSlot(placement.Slot-1, monitors)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		placement.Rect = slotRect(slot)
+		placement.IsBackdrop = layout.IsBackdrop
+		placements = append(placements, placement)
+	}
+
+	err := ApplyPlacements(placements)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("layout %s: %w", layout.Name, err))
+	} else if len(placements) > 0 {
+		activeLayout = layout.Name
+	}
+	return errors.Join(errs...)
+}
+
+// layoutPIDs returns the clients a layout is applied to: the selected target, except that a selection
+// of a single row places every listed client as it always has
+func layoutPIDs() ([]int, error) {
+	target := cmbTarget.Text()
+	if target == targetSelection && len(lstDevicesModel.SelectedProcesses()) <= 1 {
+		return lstDevicesModel.PIDs(), nil
+	}
+	return targetPIDs(target)
+}
+
+// warnSpans warns about slots spanning several monitors without bezel compensation,
+// since the picture is then cut by the bezels where the monitors meet
+func warnSpans(layout *config.Layout) {
+	if layout.Bezel != 0 {
+		return
+	}
+	for i, slot := range layout.Slots {
+		if len(slot.Span) > 1 {
+			slog.Warn("Slot spans monitors without bezel compensation, set bezel in the layout or use the driver's Eyefinity or Surround compensation",
+				"layout", layout.Name, "slot", i+1, "monitors", len(slot.Span))
+		}
+	}
+}
+
+// slotRect converts a layout slot to a window rect
+func slotRect(slot *config.Slot) win.RECT {
+	return win.RECT{
+		Left:   int32(slot.X),
+		Top:    int32(slot.Y),
+		Right:  int32(slot.X + slot.W),
+		Bottom: int32(slot.Y + slot.H),
+	}
+}
+
+// layoutNames returns the names of every configured layout
+func layoutNames() []string {
+	names := []string{}
+	for _, layout := range cfg.Layouts {
+		names = append(names, layout.Name)
+	}
+	return names
+}
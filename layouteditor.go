@@ -0,0 +1,573 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/wlk/cpl"
+	"github.com/xackery/wlk/walk"
+	"github.com/xackery/wlk/win"
+)
+
+const (
+	editorMargin    = 10 // canvas pixels around the monitors
+	editorHandle    = 6  // canvas pixels from a slot edge that start a resize
+	editorSnap      = 8  // canvas pixels within which an edge snaps to a monitor or slot edge
+	editorMinSlot   = 64 // smallest slot width or height in screen pixels
+	editorNewSlotW  = 800
+	editorNewSlotH  = 600
+	editorEdgeLeft  = 1
+	editorEdgeTop   = 2
+	editorEdgeRight = 4
+	editorEdgeDown  = 8
+)
+
+// layoutEditor edits the slots of a layout on a scaled canvas of every monitor
+type layoutEditor struct {
+	monitors []win.RECT // monitor rects in virtual screen coordinates
+	bounds   win.RECT   // union of the monitors
+	slots    []win.RECT
+	spans    []*editorSpan // per slot, nil unless the slot spans monitors
+	selected int           // index into slots, -1 if none
+	source   *config.Layout
+
+	isDragging bool
+	dragEdges  int // editorEdge flags being resized, 0 to move the whole slot
+	dragStart  walk.Point
+	dragRect   win.RECT
+
+	isUpdating bool // the numeric fields are being set from the selected slot
+
+	dlg     *walk.Dialog
+	canvas  *walk.CustomWidget
+	txtName *walk.LineEdit
+	numGrid *walk.NumberEdit
+	numX    *walk.NumberEdit
+	numY    *walk.NumberEdit
+	numW    *walk.NumberEdit
+	numH    *walk.NumberEdit
+}
+
+// editorSpan is a slot that spans monitors, shown at the rect it resolved to. It stays a span when saved
+// unless it was moved or resized
+type editorSpan struct {
+	monitors     []int
+	rect         win.RECT
+	isUnresolved bool // a monitor it spans is missing, so it is drawn over the primary monitor instead
+}
+
+// primaryMonitor returns the rect of the primary monitor, which holds the origin, or the first monitor if none does
+func (e *layoutEditor) primaryMonitor() win.RECT {
+	for _, monitor := range e.monitors {
+		if monitor.Left <= 0 && monitor.Top <= 0 && monitor.Right > 0 && monitor.Bottom > 0 {
+			return monitor
+		}
+	}
+	return e.monitors[0]
+}
+
+// spanString lists monitor numbers, e.g. 1, 2
+func spanString(monitors []int) string {
+	numbers := make([]string, len(monitors))
+	for i, number := range monitors {
+		numbers[i] = fmt.Sprint(number)
+	}
+	return strings.Join(numbers, ", ")
+}
+
+// showLayoutEditor opens the layout editor on the named layout, or an empty one if it doesn't exist.
+// It returns the name the layout was saved as, or empty if it was cancelled
+func showLayoutEditor(name string) (string, error) {
+	e := &layoutEditor{selected: -1}
+	for _, hmon := range monitors() {
+		info, ok := monitorInfo(hmon)
+		if !ok {
+			continue
+		}
+		e.monitors = append(e.monitors, info.RcMonitor)
+	}
+	if len(e.monitors) == 0 {
+		return "", fmt.Errorf("no monitors found")
+	}
+	e.bounds = e.monitors[0]
+	for _, rect := range e.monitors[1:] {
+		e.bounds = unionRect(e.bounds, rect)
+	}
+
+	e.source = cfg.Layout(name)
+	if e.source != nil {
+		rects := monitorRects()
+		for i, slot := range e.source.Slots {
+			isUnresolved := false
+			area, err := e.source.SlotArea(i, rects)
+			if err != nil {
+				slog.Warn("Slot can't be placed on the connected monitors, showing it on the primary monitor", "error", err)
+				area = fitRect(e.primaryMonitor())
+				isUnresolved = true
+			}
+			rect := windowRect(area)
+			e.slots = append(e.slots, rect)
+			var span *editorSpan
+			if len(slot.Span) > 0 {
+				span = &editorSpan{monitors: slot.Span, rect: rect, isUnresolved: isUnresolved}
+			}
+			e.spans = append(e.spans, span)
+		}
+		if len(e.slots) > 0 {
+			e.selected = 0
+		}
+	}
+
+	numberEdit := func(assignTo **walk.NumberEdit) cpl.NumberEdit {
+		return cpl.NumberEdit{
+			AssignTo:       assignTo,
+			MinValue:       -100000,
+			MaxValue:       100000,
+			MaxSize:        cpl.Size{Width: 60},
+			OnValueChanged: e.onNumberChanged,
+		}
+	}
+
+	err := cpl.Dialog{
+		AssignTo: &e.dlg,
+		Title:    "Layout Editor",
+		MinSize:  cpl.Size{Width: 800, Height: 560},
+		Layout:   cpl.VBox{},
+		Children: []cpl.Widget{
+			cpl.Composite{
+				Layout: cpl.HBox{MarginsZero: true},
+				Children: []cpl.Widget{
+					cpl.Label{Text: "Name:"},
+					cpl.LineEdit{AssignTo: &e.txtName, Text: name},
+					cpl.Label{Text: "Grid:"},
+					cpl.NumberEdit{
+						AssignTo:    &e.numGrid,
+						Value:       float64(cfg.LayoutGrid),
+						MinValue:    0,
+						MaxValue:    1000,
+						MaxSize:     cpl.Size{Width: 50},
+						ToolTipText: "Slots snap to multiples of this many pixels, 0 to turn the grid off",
+					},
+					cpl.PushButton{
+						Text:        "Add Slot",
+						ToolTipText: "Add a slot on the first monitor",
+						OnClicked:   e.addSlot,
+					},
+					cpl.PushButton{
+						Text:      "Remove Slot",
+						OnClicked: e.removeSlot,
+					},
+				},
+			},
+			cpl.CustomWidget{
+				AssignTo:            &e.canvas,
+				MinSize:             cpl.Size{Width: 640, Height: 400},
+				PaintPixels:         e.paint,
+				PaintMode:           cpl.PaintBuffered,
+				ClearsBackground:    true,
+				InvalidatesOnResize: true,
+				OnMouseDown:         e.onMouseDown,
+				OnMouseMove:         e.onMouseMove,
+				OnMouseUp:           e.onMouseUp,
+			},
+			cpl.Composite{
+				Layout: cpl.HBox{MarginsZero: true},
+				Children: []cpl.Widget{
+					cpl.Label{Text: "X:"},
+					numberEdit(&e.numX),
+					cpl.Label{Text: "Y:"},
+					numberEdit(&e.numY),
+					cpl.Label{Text: "W:"},
+					numberEdit(&e.numW),
+					cpl.Label{Text: "H:"},
+					numberEdit(&e.numH),
+					cpl.HSpacer{},
+					cpl.PushButton{
+						Text:      "Save",
+						OnClicked: e.save,
+					},
+					cpl.PushButton{
+						Text: "Cancel",
+						OnClicked: func() {
+							e.dlg.Cancel()
+						},
+					},
+				},
+			},
+		},
+	}.Create(settingsWnd)
+	if err != nil {
+		return "", fmt.Errorf("create layout editor: %w", err)
+	}
+	e.selectionChanged()
+	if e.dlg.Run() != walk.DlgCmdOK {
+		return "", nil
+	}
+	return strings.TrimSpace(e.txtName.Text()), nil
+}
+
+// save stores the slots as a layout, replacing any layout with the same name
+func (e *layoutEditor) save() {
+	name := strings.TrimSpace(e.txtName.Text())
+	if name == "" {
+		walk.MsgBox(e.dlg, "Error", "Failed to save layout: name is empty", walk.MsgBoxOK)
+		return
+	}
+	if len(e.slots) == 0 {
+		walk.MsgBox(e.dlg, "Error", "Failed to save layout: add at least one slot", walk.MsgBoxOK)
+		return
+	}
+
+	layout := &config.Layout{Name: name}
+	// fit settings are only edited in shindow.ini, so keep the ones of the layout being edited
+	if e.source != nil {
+		layout.Fit = e.source.Fit
+		layout.Aspect = e.source.Aspect
+		layout.Base = e.source.Base
+		layout.IsBackdrop = e.source.IsBackdrop
+		layout.Bezel = e.source.Bezel
+		layout.Monitors = e.source.Monitors
+	}
+	for i, rect := range e.slots {
+		if span := e.spans[i]; span != nil && span.rect == rect {
+			layout.Slots = append(layout.Slots, &config.Slot{Span: span.monitors})
+			continue
+		}
+		layout.Slots = append(layout.Slots, &config.Slot{
+			X: int(rect.Left),
+			Y: int(rect.Top),
+			W: int(rect.Right - rect.Left),
+			H: int(rect.Bottom - rect.Top),
+		})
+	}
+	cfg.SetLayout(layout)
+	cfg.LayoutGrid = int(e.numGrid.Value())
+	err := cfg.Save()
+	if err != nil {
+		errorBox("Failed to save layout", err)
+		return
+	}
+	slog.Info("Saved layout", "layout", name, "slots", len(layout.Slots))
+	e.dlg.Accept()
+}
+
+// addSlot adds a slot to the top left of the first monitor and selects it
+func (e *layoutEditor) addSlot() {
+	monitor := e.monitors[0]
+	rect := win.RECT{Left: monitor.Left, Top: monitor.Top, Right: monitor.Left + editorNewSlotW, Bottom: monitor.Top + editorNewSlotH}
+	e.slots = append(e.slots, rect)
+	e.spans = append(e.spans, nil)
+	e.selected = len(e.slots) - 1
+	e.selectionChanged()
+}
+
+// removeSlot removes the selected slot
+func (e *layoutEditor) removeSlot() {
+	if e.selected < 0 {
+		return
+	}
+	e.slots = append(e.slots[:e.selected], e.slots[e.selected+1:]...)
+	e.spans = append(e.spans[:e.selected], e.spans[e.selected+1:]...)
+	e.selected = len(e.slots) - 1
+	e.selectionChanged()
+}
+
+// selectionChanged shows the selected slot in the numeric fields and repaints
+func (e *layoutEditor) selectionChanged() {
+	e.isUpdating = true
+	defer func() { e.isUpdating = false }()
+	var rect win.RECT
+	if e.selected >= 0 {
+		rect = e.slots[e.selected]
+	}
+	e.numX.SetValue(float64(rect.Left))
+	e.numY.SetValue(float64(rect.Top))
+	e.numW.SetValue(float64(rect.Right - rect.Left))
+	e.numH.SetValue(float64(rect.Bottom - rect.Top))
+	e.canvas.Invalidate()
+}
+
+// onNumberChanged moves the selected slot to the typed rect
+func (e *layoutEditor) onNumberChanged() {
+	if e.isUpdating || e.selected < 0 {
+		return
+	}
+	x := int32(e.numX.Value())
+	y := int32(e.numY.Value())
+	w := max(int32(e.numW.Value()), editorMinSlot)
+	h := max(int32(e.numH.Value()), editorMinSlot)
+	e.slots[e.selected] = win.RECT{Left: x, Top: y, Right: x + w, Bottom: y + h}
+	e.canvas.Invalidate()
+}
+
+// scale returns the canvas pixels per screen pixel and the canvas point of the top left of the monitors
+func (e *layoutEditor) scale() (float64, walk.Point) {
+	bounds := e.canvas.ClientBoundsPixels()
+	w := float64(e.bounds.Right - e.bounds.Left)
+	h := float64(e.bounds.Bottom - e.bounds.Top)
+	scale := math.Min(float64(bounds.Width-2*editorMargin)/w, float64(bounds.Height-2*editorMargin)/h)
+	if scale <= 0 {
+		scale = 1
+	}
+	origin := walk.Point{
+		X: editorMargin + int(float64(bounds.Width-2*editorMargin)-w*scale)/2,
+		Y: editorMargin + int(float64(bounds.Height-2*editorMargin)-h*scale)/2,
+	}
+	return scale, origin
+}
+
+// toCanvas converts a screen rect to the canvas
+func (e *layoutEditor) toCanvas(rect win.RECT) walk.Rectangle {
+	scale, origin := e.scale()
+	x := origin.X + int(float64(rect.Left-e.bounds.Left)*scale)
+	y := origin.Y + int(float64(rect.Top-e.bounds.Top)*scale)
+	return walk.Rectangle{
+		X:      x,
+		Y:      y,
+		Width:  origin.X + int(float64(rect.Right-e.bounds.Left)*scale) - x,
+		Height: origin.Y + int(float64(rect.Bottom-e.bounds.Top)*scale) - y,
+	}
+}
+
+func (e *layoutEditor) paint(canvas *walk.Canvas, updateBounds walk.Rectangle) error {
+	background, err := walk.NewSolidColorBrush(walk.RGB(60, 60, 60))
+	if err != nil {
+		return fmt.Errorf("new brush: %w", err)
+	}
+	defer background.Dispose()
+	monitorBrush, err := walk.NewSolidColorBrush(walk.RGB(235, 235, 235))
+	if err != nil {
+		return fmt.Errorf("new brush: %w", err)
+	}
+	defer monitorBrush.Dispose()
+	slotBrush, err := walk.NewSolidColorBrush(walk.RGB(170, 200, 240))
+	if err != nil {
+		return fmt.Errorf("new brush: %w", err)
+	}
+	defer slotBrush.Dispose()
+	selectedBrush, err := walk.NewSolidColorBrush(walk.RGB(110, 160, 230))
+	if err != nil {
+		return fmt.Errorf("new brush: %w", err)
+	}
+	defer selectedBrush.Dispose()
+	pen, err := walk.NewCosmeticPen(walk.PenSolid, walk.RGB(30, 30, 30))
+	if err != nil {
+		return fmt.Errorf("new pen: %w", err)
+	}
+	defer pen.Dispose()
+
+	canvas.FillRectanglePixels(background, e.canvas.ClientBoundsPixels())
+	font := e.canvas.Font()
+	for i, monitor := range e.monitors {
+		bounds := e.toCanvas(monitor)
+		canvas.FillRectanglePixels(monitorBrush, bounds)
+		canvas.DrawRectanglePixels(pen, bounds)
+		label := fmt.Sprintf("%d: %dx%d", i+1, monitor.Right-monitor.Left, monitor.Bottom-monitor.Top)
+		canvas.DrawTextPixels(label, font, walk.RGB(120, 120, 120), insetRectangle(bounds, 4), walk.TextRight|walk.TextBottom|walk.TextSingleLine)
+	}
+	for i, slot := range e.slots {
+		bounds := e.toCanvas(slot)
+		brush := slotBrush
+		if i == e.selected {
+			brush = selectedBrush
+		}
+		canvas.FillRectanglePixels(brush, bounds)
+		canvas.DrawRectanglePixels(pen, bounds)
+		label := fmt.Sprintf("Slot %d\n%s", i+1, rectString(slot))
+		if span := e.spans[i]; span != nil && span.isUnresolved && span.rect == slot {
+			label = fmt.Sprintf("Slot %d\nSpans missing monitors %s, kept unless moved", i+1, spanString(span.monitors))
+		}
+		canvas.DrawTextPixels(label, font, walk.RGB(0, 0, 0), insetRectangle(bounds, 4), walk.TextLeft|walk.TextTop|walk.TextWordbreak)
+	}
+	return nil
+}
+
+func (e *layoutEditor) onMouseDown(x, y int, button walk.MouseButton) {
+	if button != walk.LeftButton {
+		return
+	}
+	point := walk.Point{X: x, Y: y}
+
+	// the selected slot is drawn on top of the ones before it, so hit test from the last slot down
+	hit := -1
+	edges := 0
+	if e.selected >= 0 {
+		edges = hitEdges(e.toCanvas(e.slots[e.selected]), point)
+		if edges != 0 {
+			hit = e.selected
+		}
+	}
+	for i := len(e.slots) - 1; hit == -1 && i >= 0; i-- {
+		if containsPoint(e.toCanvas(e.slots[i]), point) {
+			hit = i
+		}
+	}
+	e.selected = hit
+	e.selectionChanged()
+	if hit == -1 {
+		return
+	}
+	e.isDragging = true
+	e.dragEdges = edges
+	e.dragStart = point
+	e.dragRect = e.slots[hit]
+}
+
+func (e *layoutEditor) onMouseMove(x, y int, button walk.MouseButton) {
+	if !e.isDragging || e.selected < 0 {
+		return
+	}
+	scale, _ := e.scale()
+	dx := int32(math.Round(float64(x-e.dragStart.X) / scale))
+	dy := int32(math.Round(float64(y-e.dragStart.Y) / scale))
+	threshold := int32(math.Ceil(editorSnap / scale))
+	xs, ys := e.snapTargets(e.selected)
+	grid := int32(e.numGrid.Value())
+
+	rect := e.dragRect
+	if e.dragEdges == 0 {
+		rect.Left += dx
+		rect.Top += dy
+		rect.Right += dx
+		rect.Bottom += dy
+		rect = offsetRect(rect, snapMove(rect.Left, rect.Right, xs, threshold, grid), snapMove(rect.Top, rect.Bottom, ys, threshold, grid))
+	} else {
+		if e.dragEdges&editorEdgeLeft != 0 {
+			rect.Left = min(snapEdge(rect.Left+dx, xs, threshold, grid), rect.Right-editorMinSlot)
+		}
+		if e.dragEdges&editorEdgeRight != 0 {
+			rect.Right = max(snapEdge(rect.Right+dx, xs, threshold, grid), rect.Left+editorMinSlot)
+		}
+		if e.dragEdges&editorEdgeTop != 0 {
+			rect.Top = min(snapEdge(rect.Top+dy, ys, threshold, grid), rect.Bottom-editorMinSlot)
+		}
+		if e.dragEdges&editorEdgeDown != 0 {
+			rect.Bottom = max(snapEdge(rect.Bottom+dy, ys, threshold, grid), rect.Top+editorMinSlot)
+		}
+	}
+	e.slots[e.selected] = rect
+	e.selectionChanged()
+}
+
+func (e *layoutEditor) onMouseUp(x, y int, button walk.MouseButton) {
+	e.isDragging = false
+}
+
+// snapTargets returns the x and y edges of every monitor and every slot but skip
+func (e *layoutEditor) snapTargets(skip int) ([]int32, []int32) {
+	var xs, ys []int32
+	for _, rect := range e.monitors {
+		xs = append(xs, rect.Left, rect.Right)
+		ys = append(ys, rect.Top, rect.Bottom)
+	}
+	for i, rect := range e.slots {
+		if i == skip {
+			continue
+		}
+		xs = append(xs, rect.Left, rect.Right)
+		ys = append(ys, rect.Top, rect.Bottom)
+	}
+	return xs, ys
+}
+
+// snapEdge moves an edge to the nearest target within threshold, or else to the grid
+func snapEdge(value int32, targets []int32, threshold int32, grid int32) int32 {
+	best := threshold + 1
+	snapped := value
+	for _, target := range targets {
+		if d := abs32(target - value); d < best {
+			best = d
+			snapped = target
+		}
+	}
+	if best <= threshold || grid <= 0 {
+		return snapped
+	}
+	return snapGrid(value, grid)
+}
+
+// snapMove returns how far to shift a span so its nearer edge snaps to a target within threshold, or else its start to the grid
+func snapMove(start int32, end int32, targets []int32, threshold int32, grid int32) int32 {
+	best := threshold + 1
+	shift := int32(0)
+	for _, target := range targets {
+		if d := abs32(target - start); d < best {
+			best = d
+			shift = target - start
+		}
+		if d := abs32(target - end); d < best {
+			best = d
+			shift = target - end
+		}
+	}
+	if best <= threshold || grid <= 0 {
+		return shift
+	}
+	return snapGrid(start, grid) - start
+}
+
+// snapGrid rounds a value to the nearest multiple of grid
+func snapGrid(value int32, grid int32) int32 {
+	return int32(math.Round(float64(value)/float64(grid))) * grid
+}
+
+// hitEdges returns the editorEdge flags of the rect edges within the handle distance of a point
+func hitEdges(rect walk.Rectangle, point walk.Point) int {
+	if !containsPoint(insetRectangle(rect, -editorHandle), point) {
+		return 0
+	}
+	edges := 0
+	if abs(point.X-rect.X) <= editorHandle {
+		edges |= editorEdgeLeft
+	}
+	if abs(point.X-(rect.X+rect.Width)) <= editorHandle {
+		edges |= editorEdgeRight
+	}
+	if abs(point.Y-rect.Y) <= editorHandle {
+		edges |= editorEdgeTop
+	}
+	if abs(point.Y-(rect.Y+rect.Height)) <= editorHandle {
+		edges |= editorEdgeDown
+	}
+	return edges
+}
+
+func containsPoint(rect walk.Rectangle, point walk.Point) bool {
+	return point.X >= rect.X && point.X < rect.X+rect.Width && point.Y >= rect.Y && point.Y < rect.Y+rect.Height
+}
+
+// insetRectangle shrinks a rectangle by n on every side, or grows it if n is negative
+func insetRectangle(rect walk.Rectangle, n int) walk.Rectangle {
+	return walk.Rectangle{X: rect.X + n, Y: rect.Y + n, Width: rect.Width - 2*n, Height: rect.Height - 2*n}
+}
+
+func offsetRect(rect win.RECT, dx int32, dy int32) win.RECT {
+	return win.RECT{Left: rect.Left + dx, Top: rect.Top + dy, Right: rect.Right + dx, Bottom: rect.Bottom + dy}
+}
+
+// unionRect returns the smallest rect containing both a and b
+func unionRect(a win.RECT, b win.RECT) win.RECT {
+	return win.RECT{
+		Left:   min(a.Left, b.Left),
+		Top:    min(a.Top, b.Top),
+		Right:  max(a.Right, b.Right),
+		Bottom: max(a.Bottom, b.Bottom),
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xackery/shindow/logging"
+	"github.com/xackery/wlk/walk"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	logFileName     = "shindow.log"
+	logMaxBytes     = 5 * 1024 * 1024
+	logBackups      = 3
+	logViewLines    = 500
+	logViewInterval = 500 * time.Millisecond
+)
+
+var (
+	logLevel = new(slog.LevelVar)
+	// logRing holds the tail of the log shown in the log pane
+	logRing = logging.NewRing(logViewLines)
+	txtLog  *walk.TextEdit
+)
+
+// setupLogging sends slog output to the log pane and a rotating shindow.log in dir
+func setupLogging(dir string) (io.Closer, error) {
+	file, err := logging.OpenRotatingFile(filepath.Join(dir, logFileName), logMaxBytes, logBackups)
+	if err != nil {
+		return nil, err
+	}
+	handler := slog.NewTextHandler(io.MultiWriter(logRing, file), &slog.HandlerOptions{Level: logLevel})
+	slog.SetDefault(slog.New(handler))
+	return file, nil
+}
+
+// setLogLevel changes the level of the running logger, e.g. debug or warn
+func setLogLevel(value string) error {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(value))
+	if err != nil {
+		return fmt.Errorf("log level %s: %w", value, err)
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+// logViewLoop copies new log lines into the log pane until done is closed
+func logViewLoop(done chan struct{}) {
+	ticker := time.NewTicker(logViewInterval)
+	defer ticker.Stop()
+	shown := uint64(0)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		version := logRing.Version()
+		if version == shown {
+			continue
+		}
+		shown = version
+		text := strings.Join(logRing.Lines(), "\r\n")
+		settingsWnd.Synchronize(func() {
+			txtLog.SetText(text)
+			txtLog.SetTextSelection(len(text), len(text))
+			txtLog.ScrollToCaret()
+		})
+	}
+}
+
+// windowAttrs returns the log attributes identifying a window
+func windowAttrs(hwnd windows.HWND) []any {
+	return []any{"pid", pidByHWND(uintptr(hwnd)), "hwnd", fmt.Sprintf("0x%X", hwnd)}
+}
+
+// rectString formats a rect as x,y wxh for logs
+func rectString(rect win.RECT) string {
+	return fmt.Sprintf("%d,%d %dx%d", rect.Left, rect.Top, rect.Right-rect.Left, rect.Bottom-rect.Top)
+}
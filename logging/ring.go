@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"strings"
+	"sync"
+)
+
+// Ring keeps the last lines written to it, for showing the log in the GUI
+type Ring struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial string
+	version uint64
+}
+
+// NewRing returns a ring that keeps up to max lines
+func NewRing(max int) *Ring {
+	return &Ring{max: max}
+}
+
+// Write adds complete lines to the ring, holding on to any trailing partial line
+func (r *Ring) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	text := r.partial + string(p)
+	lines := strings.Split(text, "\n")
+	r.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		r.lines = append(r.lines, strings.TrimSuffix(line, "\r"))
+	}
+	if len(r.lines) > r.max {
+		r.lines = append([]string(nil), r.lines[len(r.lines)-r.max:]...)
+	}
+	r.version++
+	return len(p), nil
+}
+
+// Lines returns a copy of the kept lines, oldest first
+func (r *Ring) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+// Version changes every time the ring is written to
+func (r *Ring) Version() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.version
+}
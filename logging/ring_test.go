@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    int
+		writes []string
+		want   []string
+	}{
+		{
+			name:   "complete lines",
+			max:    5,
+			writes: []string{"one\n", "two\n"},
+			want:   []string{"one", "two"},
+		},
+		{
+			name:   "several lines in one write",
+			max:    5,
+			writes: []string{"one\ntwo\nthree\n"},
+			want:   []string{"one", "two", "three"},
+		},
+		{
+			name:   "partial line is held",
+			max:    5,
+			writes: []string{"one\ntw"},
+			want:   []string{"one"},
+		},
+		{
+			name:   "partial line is joined",
+			max:    5,
+			writes: []string{"one\ntw", "o\nthr", "ee\n"},
+			want:   []string{"one", "two", "three"},
+		},
+		{
+			name:   "crlf",
+			max:    5,
+			writes: []string{"one\r\ntwo\r", "\n"},
+			want:   []string{"one", "two"},
+		},
+		{
+			name:   "empty lines",
+			max:    5,
+			writes: []string{"one\n\ntwo\n"},
+			want:   []string{"one", "", "two"},
+		},
+		{
+			name:   "keeps the last lines",
+			max:    2,
+			writes: []string{"one\n", "two\n", "three\nfour\n"},
+			want:   []string{"three", "four"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRing(tt.max)
+			for _, write := range tt.writes {
+				n, err := r.Write([]byte(write))
+				if err != nil || n != len(write) {
+					t.Fatalf("Write(%q) = %d, %v", write, n, err)
+				}
+			}
+			got := r.Lines()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRingVersion(t *testing.T) {
+	r := NewRing(2)
+	before := r.Version()
+	r.Write([]byte("partial"))
+	if r.Version() == before {
+		t.Error("Version did not change after a write")
+	}
+}
+
+func TestRingLinesCopy(t *testing.T) {
+	r := NewRing(2)
+	r.Write([]byte("one\ntwo\n"))
+	lines := r.Lines()
+	lines[0] = "changed"
+	if got := r.Lines(); got[0] != "one" {
+		t.Errorf("Lines() = %q after changing a returned copy", got)
+	}
+}
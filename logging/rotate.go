@@ -0,0 +1,117 @@
+// Package logging provides the writers behind Shindow's log: a size rotated file and an in memory tail for the GUI
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is a log file that is renamed to path.1, path.2 and so on once it grows past a size
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	backups  int
+	file     *os.File
+	size     int64
+}
+
+// OpenRotatingFile opens or creates a log file, keeping up to backups old files of maxBytes each
+func OpenRotatingFile(path string, maxBytes int64, backups int) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:     path,
+		maxBytes: maxBytes,
+		backups:  backups,
+	}
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log: %w", err)
+	}
+	r.file = file
+	r.size = fi.Size()
+	return nil
+}
+
+// Write appends to the log, rotating it first if p would make it too large. If the log can't be rotated
+// it keeps growing, and rotating is tried again on the next write
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, fmt.Errorf("log is closed")
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		err := r.rotate()
+		if r.file == nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts every backup up by one, dropping the oldest, and starts a new file.
+// If the log can't be moved aside, the current file is opened again so logging carries on
+func (r *RotatingFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		err = fmt.Errorf("close log: %w", err)
+	} else {
+		err = r.shift()
+	}
+	if err != nil {
+		openErr := r.open()
+		if openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+	return r.open()
+}
+
+// shift moves the log to path.1 and every backup up by one, dropping the oldest
+func (r *RotatingFile) shift() error {
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.backups))
+	for i := r.backups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	var err error
+	if r.backups > 0 {
+		err = os.Rename(r.path, r.path+".1")
+	} else {
+		err = os.Remove(r.path)
+	}
+	if err != nil {
+		return fmt.Errorf("rotate log: %w", err)
+	}
+	return nil
+}
+
+// Close closes the log file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
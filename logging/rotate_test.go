@@ -0,0 +1,175 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readLogs returns the content of the log and each of its backups, missing files as empty
+func readLogs(t *testing.T, path string, backups int) []string {
+	t.Helper()
+	var logs []string
+	for i := 0; i <= backups; i++ {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		logs = append(logs, string(data))
+	}
+	return logs
+}
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		maxBytes int64
+		backups  int
+		writes   []string
+		want     []string // the log, then path.1, path.2 and so on
+	}{
+		{
+			name:     "under the limit",
+			maxBytes: 10,
+			backups:  2,
+			writes:   []string{"aaaa\n", "bbbb\n"},
+			want:     []string{"aaaa\nbbbb\n", "", ""},
+		},
+		{
+			name:     "rotates past the limit",
+			maxBytes: 10,
+			backups:  2,
+			writes:   []string{"aaaa\n", "bbbb\n", "cccc\n"},
+			want:     []string{"cccc\n", "aaaa\nbbbb\n", ""},
+		},
+		{
+			name:     "shifts backups",
+			maxBytes: 5,
+			backups:  2,
+			writes:   []string{"aaaa\n", "bbbb\n", "cccc\n"},
+			want:     []string{"cccc\n", "bbbb\n", "aaaa\n"},
+		},
+		{
+			name:     "drops the oldest backup",
+			maxBytes: 5,
+			backups:  2,
+			writes:   []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"},
+			want:     []string{"dddd\n", "cccc\n", "bbbb\n"},
+		},
+		{
+			name:     "no backups",
+			maxBytes: 5,
+			writes:   []string{"aaaa\n", "bbbb\n"},
+			want:     []string{"bbbb\n"},
+		},
+		{
+			name:     "counts an existing log",
+			existing: "old\n",
+			maxBytes: 6,
+			backups:  1,
+			writes:   []string{"new\n"},
+			want:     []string{"new\n", "old\n"},
+		},
+		{
+			name:     "write larger than the limit",
+			maxBytes: 4,
+			backups:  1,
+			writes:   []string{"aaaaaaaa\n"},
+			want:     []string{"aaaaaaaa\n", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shindow.log")
+			if tt.existing != "" {
+				err := os.WriteFile(path, []byte(tt.existing), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			r, err := OpenRotatingFile(path, tt.maxBytes, tt.backups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, write := range tt.writes {
+				n, err := r.Write([]byte(write))
+				if err != nil || n != len(write) {
+					t.Fatalf("Write(%q) = %d, %v", write, n, err)
+				}
+			}
+			err = r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := readLogs(t, path, tt.backups)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("logs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotatingFileRenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shindow.log")
+	r, err := OpenRotatingFile(path, 5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// a directory in the way of path.1 makes moving the log aside fail
+	err = os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, write := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		n, err := r.Write([]byte(write))
+		if err != nil || n != len(write) {
+			t.Fatalf("Write(%q) = %d, %v", write, n, err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "aaaa\nbbbb\ncccc\n" {
+		t.Errorf("log = %q, want every write appended", data)
+	}
+
+	// rotating is tried again once the way is clear
+	err = os.RemoveAll(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Write([]byte("dddd\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readLogs(t, path, 1)
+	want := []string{"dddd\n", "aaaa\nbbbb\ncccc\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("logs = %q, want %q", got, want)
+	}
+}
+
+func TestRotatingFileClosed(t *testing.T) {
+	r, err := OpenRotatingFile(filepath.Join(t.TempDir(), "shindow.log"), 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Write([]byte("late\n"))
+	if err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
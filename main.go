@@ -1,16 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
+	"sync"
 	"syscall"
 	"unsafe"
 
-	"github.com/shirou/gopsutil/process"
 	"github.com/xackery/shindow/config"
+	"github.com/xackery/shindow/winerr"
+	"github.com/xackery/shindow/winstyle"
 	"github.com/xackery/wlk/cpl"
 	"github.com/xackery/wlk/walk"
 	"github.com/xackery/wlk/win"
@@ -27,18 +30,43 @@ var (
 	settingsWnd         *walk.MainWindow
 	cfg                 *config.CastConfiguration
 	lstDevicesModel     *ProcessModel
-	lstDevices          *walk.ListBox
+	tblProcesses        *walk.TableView
 	btnToggleBorderless *walk.PushButton
 	txtResolutionX      *walk.TextEdit
 	txtResolutionY      *walk.TextEdit
 	txtResolutionW      *walk.TextEdit
 	txtResolutionH      *walk.TextEdit
+	cmbLayout           *walk.ComboBox
+	cmbProfile          *walk.ComboBox
+	cmbPriority         *walk.ComboBox
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "focus" {
+		attachConsole()
+		err := runFocus(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "focus: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diagnose" {
+		attachConsole()
+		err := runDiagnose(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "diagnose: %v\n", err)
+			if hint := winerr.Hint(err); hint != "" {
+				fmt.Fprintf(os.Stderr, "hint: %s\n", hint)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
 	err := run()
 	if err != nil {
-		walk.MsgBox(nil, "Error", fmt.Sprintf("Failed to run: "+err.Error()), walk.MsgBoxOK)
+		errorBox("Failed to run", err)
 	}
 }
 
@@ -47,10 +75,21 @@ func run() error {
 
 	exePath := os.Args[0]
 
+	logFile, err := setupLogging(filepath.Dir(exePath))
+	if err != nil {
+		return fmt.Errorf("setup logging: %w", err)
+	}
+	defer logFile.Close()
+
 	cfg, err = config.LoadCastConfig(filepath.Dir(exePath) + "/shindow.ini")
 	if err != nil {
 		return fmt.Errorf("load config: %w", err)
 	}
+	err = setLogLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	slog.Info("Started shindow", "version", Version)
 
 	lstDevicesModel = &ProcessModel{}
 
@@ -59,6 +98,7 @@ func run() error {
 		return fmt.Errorf("list processes: %w", err)
 	}
 	lstDevicesModel.Set(processes)
+	tuneClients(processes)
 
 	cmw := cpl.MainWindow{
 		Title:    "Shindow Borderless v" + Version,
@@ -70,11 +110,24 @@ func run() error {
 				Title:  "Processes",
 				Layout: cpl.VBox{},
 				Children: []cpl.Widget{
-					cpl.ListBox{
-						ToolTipText: "Select which copy of EverQuest to fullscreen",
-						AssignTo:    &lstDevices,
-						Model:       lstDevicesModel,
-						OnCurrentIndexChanged: func() {
+					cpl.TableView{
+						ToolTipText:    "Select which copies of EverQuest the actions apply to, hold Ctrl or Shift to select several",
+						AssignTo:       &tblProcesses,
+						MultiSelection: true,
+						Model:          lstDevicesModel,
+						StyleCell:      lstDevicesModel.StyleCell,
+						Columns: []cpl.TableViewColumn{
+							{Title: "Name", Width: 80},
+							{Title: "PID", Width: 50},
+							{Title: "Title", Width: 120},
+							{Title: "HWND", Width: 70},
+							{Title: "Rect", Width: 110},
+							{Title: "Borderless", Width: 65},
+							{Title: "Monitor", Width: 55},
+							{Title: "Started", Width: 60},
+							{Title: "CPU", Width: 50},
+							{Title: "Memory", Width: 65},
+							{Title: "Exe", Width: 200},
 						},
 					},
 					cpl.Composite{
@@ -86,9 +139,31 @@ func run() error {
 								OnClicked: func() {
 									processes, err := listProcesses()
 									if err != nil {
-										fmt.Printf("listProcesses: %v\n", err)
+										slog.Error("Failed to list processes", "error", err)
+									}
+									setProcesses(processes)
+								},
+							},
+							cpl.PushButton{
+								Text:        "Inspect",
+								ToolTipText: "Show every window of the selected client with its decoded style, rects, monitor and DPI",
+								OnClicked: func() {
+									err := showInspector(lstDevicesModel.SelectedProcess())
+									if err != nil {
+										errorBox("Failed to inspect", err)
 									}
-									lstDevicesModel.Set(processes)
+								},
+							},
+							cpl.Composite{
+								Layout: cpl.HBox{},
+								Children: []cpl.Widget{
+									cpl.Label{Text: "Target:"},
+									cpl.ComboBox{
+										AssignTo:     &cmbTarget,
+										Model:        targetNames(),
+										CurrentIndex: 0,
+										ToolTipText:  "Clients the borderless, window and layout actions apply to. Groups are defined as [group name] sections in shindow.ini",
+									},
 								},
 							},
 							cpl.GroupBox{
@@ -101,7 +176,7 @@ func run() error {
 										OnClicked: func() {
 											hwnd, err := hwndByPID(lstDevicesModel.SelectedProcess())
 											if err != nil {
-												walk.MsgBox(nil, "Error", fmt.Sprintf("Failed to find hwnd: "+err.Error()), walk.MsgBoxOK)
+												errorBox("Failed to find hwnd", err)
 												return
 											}
 
@@ -109,7 +184,7 @@ func run() error {
 											var monitorInfo win.MONITORINFO
 											monitorInfo.CbSize = uint32(unsafe.Sizeof(monitorInfo))
 											if !win.GetMonitorInfo(monitor, &monitorInfo) {
-												walk.MsgBox(nil, "Error", fmt.Sprintf("GetMonitorInfo failed: %s", lastErrorMessage()), walk.MsgBoxOK)
+												errorBox("Failed to set dimension", winerr.New(winerr.ErrInvalidRect, "GetMonitorInfo primary monitor", syscall.GetLastError()))
 												return
 											}
 
@@ -126,13 +201,13 @@ func run() error {
 										OnClicked: func() {
 											hwnd, err := hwndByPID(lstDevicesModel.SelectedProcess())
 											if err != nil {
-												walk.MsgBox(nil, "Error", fmt.Sprintf("Failed to find hwnd: "+err.Error()), walk.MsgBoxOK)
+												errorBox("Failed to find hwnd", err)
 												return
 											}
 
 											var rect win.RECT
 											if !win.GetWindowRect(hwnd, &rect) {
-												walk.MsgBox(nil, "Error", fmt.Sprintf("GetWindowRect failed: %s", lastErrorMessage()), walk.MsgBoxOK)
+												errorBox("Failed to set dimension", winerr.New(winerr.ErrNoWindow, "GetWindowRect", syscall.GetLastError()))
 												return
 											}
 
@@ -184,23 +259,35 @@ func run() error {
 								},
 							},
 
+							cpl.PushButton{
+								Text:        "Check eqclient.ini",
+								ToolTipText: "Compare the selected client's eqclient.ini video mode with the resolution above",
+								OnClicked: func() {
+									err := checkEQClient(lstDevicesModel.SelectedProcess())
+									if err != nil {
+										errorBox("Failed to check eqclient.ini", err)
+									}
+								},
+							},
 							cpl.PushButton{
 								AssignTo: &btnToggleBorderless,
 								Text:     "Set Fullscreen Borderless",
 								//MaxSize:  cpl.Size{Width: 45},
 								ToolTipText: "Go into options, display, turn off Allow window resizing, Overlap windows taskbar, and go to video modes and set it to your resolution",
 								OnClicked: func() {
-
-									hwnd, err := hwndByPID(lstDevicesModel.SelectedProcess())
+									rect, err := resolutionRect()
 									if err != nil {
-										walk.MsgBox(nil, "Error", fmt.Sprintf("Failed to find hwnd: "+err.Error()), walk.MsgBoxOK)
+										errorBox("Failed to parse resolution", err)
 										return
 									}
 
-									err = ToggleBorderlessWindow(hwnd, true)
-									if err != nil {
-										walk.MsgBox(nil, "Error", fmt.Sprintf("Failed to set fullscreen borderless: "+err.Error()), walk.MsgBoxOK)
-									}
+									runTargetAction("set fullscreen borderless", func(pid int) error {
+										hwnd, err := hwndByPID(pid)
+										if err != nil {
+											return err
+										}
+										return ToggleBorderlessWindow(hwnd, true, rect)
+									})
 								},
 							},
 							cpl.PushButton{
@@ -209,30 +296,175 @@ func run() error {
 								//MaxSize:  cpl.Size{Width: 45},
 								ToolTipText: "Go into options, display, turn off Allow window resizing, Overlap windows taskbar, and go to video modes and set it to your resolution",
 								OnClicked: func() {
-
-									hwnd, err := hwndByPID(lstDevicesModel.SelectedProcess())
-									if err != nil {
-										walk.MsgBox(nil, "Error", fmt.Sprintf("Failed to find hwnd: "+err.Error()), walk.MsgBoxOK)
-										return
-									}
-
-									err = ToggleBorderlessWindow(hwnd, false)
-									if err != nil {
-										walk.MsgBox(nil, "Error", fmt.Sprintf("Failed to reset fullscreen borderless: "+err.Error()), walk.MsgBoxOK)
-									}
+									runTargetAction("reset fullscreen borderless", func(pid int) error {
+										hwnd, err := hwndByPID(pid)
+										if err != nil {
+											return err
+										}
+										return ToggleBorderlessWindow(hwnd, false, win.RECT{})
+									})
+								},
+							},
+							cpl.GroupBox{
+								Title:  "Windows",
+								Layout: cpl.HBox{},
+								Children: []cpl.Widget{
+									cpl.PushButton{
+										Text:        "Minimize",
+										ToolTipText: "Minimize the target clients",
+										OnClicked: func() {
+											runTargetAction("minimize", func(pid int) error {
+												hwnd, err := hwndByPID(pid)
+												if err != nil {
+													return err
+												}
+												return minimizeWindow(hwnd)
+											})
+										},
+									},
+									cpl.PushButton{
+										Text:        "Restore",
+										ToolTipText: "Restore the target clients from the taskbar, putting managed clients back into their rect and borderless style",
+										OnClicked: func() {
+											runTargetAction("restore", func(pid int) error {
+												hwnd, err := hwndByPID(pid)
+												if err != nil {
+													return err
+												}
+												return restoreWindow(hwnd)
+											})
+										},
+									},
+									cpl.ComboBox{
+										AssignTo:     &cmbPriority,
+										Model:        priorityNames(),
+										CurrentIndex: 2,
+										ToolTipText:  "Priority class set by Set Priority, focus policies restore to it",
+									},
+									cpl.PushButton{
+										Text:        "Set Priority",
+										ToolTipText: "Set the priority class of the target clients",
+										OnClicked: func() {
+											priority := config.Priority(cmbPriority.Text())
+											runTargetAction("set priority", func(pid int) error {
+												return setClientPriority(pid, priority)
+											})
+										},
+									},
+								},
+							},
+							cpl.GroupBox{
+								Title:  "Layout",
+								Layout: cpl.HBox{},
+								Children: []cpl.Widget{
+									cpl.ComboBox{
+										AssignTo:    &cmbLayout,
+										Model:       layoutNames(),
+										ToolTipText: "Layouts are defined as [layout name] sections in shindow.ini",
+									},
+									cpl.PushButton{
+										Text:        "Apply",
+										ToolTipText: "Place the target clients into the layout's slots in list order. With a single row selected every listed client is placed",
+										OnClicked: func() {
+											pids, err := layoutPIDs()
+											if err != nil {
+												errorBox("Failed to apply layout", err)
+												return
+											}
+											results, err := applyLayout(cfg.Layout(cmbLayout.Text()), pids)
+											if err != nil {
+												errorBox("Failed to apply layout", err)
+												return
+											}
+											reportResults("apply layout", results)
+										},
+									},
+									cpl.PushButton{
+										Text:        "Edit",
+										ToolTipText: "Draw the selected layout's slots over your monitors, rename it in the editor to save a new layout",
+										OnClicked: func() {
+											name, err := showLayoutEditor(cmbLayout.Text())
+											if err != nil {
+												errorBox("Failed to edit layout", err)
+												return
+											}
+											if name == "" {
+												return
+											}
+											names := layoutNames()
+											cmbLayout.SetModel(names)
+											for i, n := range names {
+												if n == name {
+													cmbLayout.SetCurrentIndex(i)
+												}
+											}
+										},
+									},
 								},
 							},
 						},
 					},
 				},
 			},
+			cpl.GroupBox{
+				Title:  "Launch",
+				Layout: cpl.HBox{},
+				Children: []cpl.Widget{
+					cpl.ComboBox{
+						AssignTo:    &cmbProfile,
+						Model:       profileNames(),
+						ToolTipText: "Profiles are defined as [profile name] sections in shindow.ini",
+					},
+					cpl.PushButton{
+						Text:        "Launch",
+						ToolTipText: "Start the selected profile and place it into its layout slot",
+						OnClicked: func() {
+							profile := cfg.Profile(cmbProfile.Text())
+							if profile == nil {
+								walk.MsgBox(nil, "Error", "Failed to launch: no profile selected", walk.MsgBoxOK)
+								return
+							}
+							launchProfilesAsync([]*config.Profile{profile})
+						},
+					},
+					cpl.PushButton{
+						Text:        "Launch All",
+						ToolTipText: "Start every profile and arrange them all at once",
+						OnClicked: func() {
+							launchProfilesAsync(cfg.Profiles)
+						},
+					},
+					cpl.CheckBox{
+						AssignTo:    &chkFocusPolicies,
+						Text:        "Focus Policies",
+						Checked:     true,
+						ToolTipText: "Drop the priority, narrow the affinity or suspend other clients while the main slot has focus, per background_* and suspend_after. Uncheck to restore them all",
+						OnCheckedChanged: func() {
+							setFocusPolicies(chkFocusPolicies.Checked())
+						},
+					},
+				},
+			},
+			cpl.GroupBox{
+				Title:  "Log",
+				Layout: cpl.VBox{},
+				Children: []cpl.Widget{
+					cpl.TextEdit{
+						AssignTo: &txtLog,
+						ReadOnly: true,
+						VScroll:  true,
+						MinSize:  cpl.Size{Height: 120},
+						Font:     cpl.Font{Family: "Consolas", PointSize: 8},
+					},
+				},
+			},
 			cpl.PushButton{
 				Text:    "Save",
 				MaxSize: cpl.Size{Width: 45},
 				OnClicked: func() {
 					err := updateSave()
 					if err != nil {
-						walk.MsgBox(nil, "Error", fmt.Sprintf("Failed to save: "+err.Error()), walk.MsgBoxOK)
+						errorBox("Failed to save", err)
 					}
 				},
 			},
@@ -253,13 +485,41 @@ func run() error {
 	settingsWnd.SetHeight(cfg.SettingsH)
 
 	if lstDevicesModel.ItemCount() == 1 {
-		lstDevices.SetCurrentIndex(0)
+		tblProcesses.SetCurrentIndex(0)
+	}
+
+	refreshDone := make(chan struct{})
+	defer close(refreshDone)
+	go refreshProcessesLoop(refreshDone)
+	go driftLoop(refreshDone)
+	go logViewLoop(refreshDone)
+
+	// titles and priorities are restored after the event thread stops, so it can't change them again
+	defer restoreTitles()
+	defer restoreCPU()
+	events := startEventThread()
+	defer events.stop()
+
+	if len(cfg.Layouts) > 0 {
+		cmbLayout.SetCurrentIndex(0)
+	}
+
+	if len(cfg.Profiles) > 0 {
+		cmbProfile.SetCurrentIndex(0)
+	}
+
+	ipcServer, err := startIPC()
+	if err != nil {
+		return fmt.Errorf("start ipc: %w", err)
+	}
+	if ipcServer != nil {
+		defer ipcServer.Close()
 	}
 
 	settingsWnd.Closing().Attach(func(isCancel *bool, reason byte) {
 		err := updateSave()
 		if err != nil {
-			fmt.Printf("updateSave post attach: %v\n", err)
+			slog.Error("Failed to save config on close", "error", err)
 		}
 	})
 
@@ -268,192 +528,93 @@ func run() error {
 	return nil
 }
 
-// ProcessModel represents a model for processes
-type ProcessModel struct {
-	walk.ListModelBase
-	entries []*ProcessEntry
-}
-
-// ProcessEntry is an entry for a process, PID and name
-type ProcessEntry struct {
-	Name string
-	PID  int
-}
-
-// Set sets the items
-func (m *ProcessModel) Set(items []*ProcessEntry) {
-	m.entries = items
-	m.PublishItemsReset()
-}
-
-// ItemCount returns the number of items
-func (m *ProcessModel) ItemCount() int {
-	return len(m.entries)
-}
-
-// Value returns the value of an item
-func (m *ProcessModel) Value(index int) interface{} {
-	if index < 0 || index >= len(m.entries) {
-		return nil
-	}
-	return fmt.Sprintf("%s (%d)", m.entries[index].Name, m.entries[index].PID)
-}
-
-func (m *ProcessModel) PID(index int) int {
-	if index < 0 || index >= len(m.entries) {
-		return 0
-	}
-	return m.entries[index].PID
-}
-
-func (m *ProcessModel) SelectedProcess() int {
-	if lstDevices.CurrentIndex() == -1 {
-		return 0
-	}
-	return m.PID(lstDevices.CurrentIndex())
-}
-
-func listProcesses() ([]*ProcessEntry, error) {
-	var processes []*ProcessEntry
-	procs, err := process.Processes()
+// ToggleBorderlessWindow strips or restores the frame of a window. When made borderless
+// the window is moved to rect, otherwise rect is ignored
+func ToggleBorderlessWindow(hwnd windows.HWND, isBorderless bool, rect win.RECT) error {
+	wakeWindow(hwnd)
+	err := checkAccess(hwnd)
 	if err != nil {
-		return nil, fmt.Errorf("processes: %w", err)
+		return err
 	}
-	for _, proc := range procs {
 
-		name, err := proc.Name()
+	if !isBorderless {
+		err = setBorderlessStyle(hwnd, managedRecipe(hwnd), false)
 		if err != nil {
-			continue
+			return err
 		}
-		if !strings.Contains(name, "eqgame.exe") {
-			continue
+		// a window that was maximized when it was made borderless goes back to maximized
+		if wasMaximized(hwnd) {
+			err = showWindow(hwnd, win.SW_MAXIMIZE)
+			if err != nil {
+				return err
+			}
+			slog.Info("Maximized window again", windowAttrs(hwnd)...)
 		}
-		pid := proc.Pid
-		processes = append(processes, &ProcessEntry{
-			Name: name,
-			PID:  int(pid),
-		})
+		unmanageWindow(hwnd)
+		return nil
 	}
-	return processes, nil
+
+	// a maximized window is restored by ApplyPlacements, or refused if its rule says so
+	return ApplyPlacements([]*Placement{{HWND: hwnd, Rect: rect, IsBorderless: true}})
 }
 
-func ToggleBorderlessWindow(hwnd windows.HWND, isBorderless bool) error {
-	if win.IsZoomed(hwnd) {
-		return fmt.Errorf("window is maximized, please restore it first")
-	}
-	//if isBorderless {
-	//if win.IsZoomed(hwnd) && !win.ShowWindow(hwnd, win.SW_RESTORE) {
-	//	return fmt.Errorf("failed to restore maximized window: %w", syscall.GetLastError())
-	//}
-	//}
-	// Remove the title bar and borders by updating the window style
-	style := win.GetWindowLong(hwnd, win.GWL_STYLE)
-	if isBorderless {
-		style &^= win.WS_CAPTION     // Remove WS_CAPTION
-		style &^= win.WS_THICKFRAME  // Remove WS_THICKFRAME (border)
-		style &^= win.WS_MINIMIZEBOX // Remove WS_MINIMIZEBOX
-		style &^= win.WS_MAXIMIZEBOX // Remove WS_MAXIMIZEBOX
-		style &^= win.WS_SYSMENU     // Remove WS_SYSMENU
-	} else {
-		style |= win.WS_CAPTION          // Add WS_CAPTION
-		style |= win.WS_THICKFRAME       // Add WS_THICKFRAME (border)
-		style |= win.WS_MINIMIZEBOX      // Add WS_MINIMIZEBOX
-		style |= win.WS_MAXIMIZEBOX      // Add WS_MAXIMIZEBOX
-		style |= win.WS_OVERLAPPEDWINDOW // Add WS_OVERLAPPEDWINDOW
-		style |= win.WS_SYSMENU          // Add WS_SYSMENU
-	}
-
-	ret := win.SetWindowLong(hwnd, win.GWL_STYLE, style)
-	if ret == 0 {
-		return fmt.Errorf("SetWindowLong failed: %w", syscall.GetLastError())
-	}
-
-	// Remove extended styles that might conflict
-	exStyle := win.GetWindowLong(hwnd, win.GWL_EXSTYLE)
+// setBorderlessStyle removes or adds the title bar and borders by applying or undoing a recipe
+func setBorderlessStyle(hwnd windows.HWND, recipe *config.Recipe, isBorderless bool) error {
+	oldStyle := uint32(win.GetWindowLong(hwnd, win.GWL_STYLE))
+	oldExStyle := uint32(win.GetWindowLong(hwnd, win.GWL_EXSTYLE))
+
+	style, exStyle := recipe.Undo(oldStyle, oldExStyle)
 	if isBorderless {
-		exStyle &^= win.WS_EX_DLGMODALFRAME
-		exStyle &^= win.WS_EX_CLIENTEDGE
-		exStyle &^= win.WS_EX_STATICEDGE
-	} else {
-		exStyle |= win.WS_EX_DLGMODALFRAME
-		exStyle |= win.WS_EX_CLIENTEDGE
-		exStyle |= win.WS_EX_STATICEDGE
-	}
-	ret = win.SetWindowLong(hwnd, win.GWL_EXSTYLE, exStyle)
-	if ret == 0 {
-		return fmt.Errorf("SetWindowLong failed: %w", syscall.GetLastError())
+		style, exStyle = recipe.Apply(oldStyle, oldExStyle)
 	}
 
-	if !isBorderless {
-		//maximize the window
-		// if !win.ShowWindow(hwnd, win.SW_MAXIMIZE) {
-		// 	return fmt.Errorf("failed to maximize window: %w", syscall.GetLastError())
-		// }
-		return nil
+	err := setWindowLong(hwnd, win.GWL_STYLE, int32(style))
+	if err != nil {
+		err = winerr.New(winerr.ErrStyleRejected, "SetWindowLong style "+winstyle.FormatStyle(style), err)
+		slog.Error("Failed to set window style", append(windowAttrs(hwnd), "recipe", recipe.Name, "error", err)...)
+		return err
 	}
+	slog.Info("Changed window style", append(windowAttrs(hwnd), "recipe", recipe.Name, "borderless", isBorderless, "before", winstyle.FormatStyle(oldStyle), "after", winstyle.FormatStyle(style))...)
 
-	// Get the monitor dimensions
-	monitor := win.MonitorFromWindow(hwnd, win.MONITOR_DEFAULTTOPRIMARY)
-	var monitorInfo win.MONITORINFO
-	monitorInfo.CbSize = uint32(unsafe.Sizeof(monitorInfo))
-	if !win.GetMonitorInfo(monitor, &monitorInfo) {
-		return fmt.Errorf("GetMonitorInfo failed: %w", syscall.GetLastError())
+	err = setWindowLong(hwnd, win.GWL_EXSTYLE, int32(exStyle))
+	if err != nil {
+		err = winerr.New(winerr.ErrStyleRejected, "SetWindowLong ex-style "+winstyle.FormatExStyle(exStyle), err)
+		slog.Error("Failed to set window ex-style", append(windowAttrs(hwnd), "recipe", recipe.Name, "error", err)...)
+		return err
 	}
+	slog.Info("Changed window ex-style", append(windowAttrs(hwnd), "recipe", recipe.Name, "borderless", isBorderless, "before", winstyle.FormatExStyle(oldExStyle), "after", winstyle.FormatExStyle(exStyle))...)
+	return nil
+}
 
-	// if the montior's full screen resolution matches the target resolution, then top/left is 0,0
-	// otherwise, top/left is based onthe current window's position
-
-	rect := win.RECT{Left: 0, Top: 0, Right: 0, Bottom: 0}
-	if !win.GetWindowRect(hwnd, &rect) {
-		return fmt.Errorf("GetWindowRect failed: %w", syscall.GetLastError())
-	}
+// resolutionRect returns the rect typed into the resolution fields
+func resolutionRect() (win.RECT, error) {
+	rect := win.RECT{}
 
 	val, err := strconv.Atoi(txtResolutionX.Text())
 	if err != nil {
-		return fmt.Errorf("x: %w", err)
+		return rect, winerr.New(winerr.ErrInvalidRect, "parse x", err)
 	}
 	rect.Left = int32(val)
 
 	val, err = strconv.Atoi(txtResolutionY.Text())
 	if err != nil {
-		return fmt.Errorf("y: %w", err)
+		return rect, winerr.New(winerr.ErrInvalidRect, "parse y", err)
 	}
 	rect.Top = int32(val)
 
 	val, err = strconv.Atoi(txtResolutionW.Text())
 	if err != nil {
-		return fmt.Errorf("w: %w", err)
+		return rect, winerr.New(winerr.ErrInvalidRect, "parse w", err)
 	}
 	rect.Right = rect.Left + int32(val)
 
 	val, err = strconv.Atoi(txtResolutionH.Text())
 	if err != nil {
-		return fmt.Errorf("h: %w", err)
+		return rect, winerr.New(winerr.ErrInvalidRect, "parse h", err)
 	}
 	rect.Bottom = rect.Top + int32(val)
 
-	// Set the window to cover the entire monitor area
-
-	if !win.SetWindowPos(hwnd, win.HWND_NOTOPMOST, rect.Left, rect.Top,
-		rect.Right-rect.Left, rect.Bottom-rect.Top,
-		win.SWP_FRAMECHANGED|win.SWP_NOOWNERZORDER|win.SWP_NOZORDER) {
-		return fmt.Errorf("SetWindowPos failed: %w", syscall.GetLastError())
-	}
-
-	fmt.Printf("Setting resolution to %dx%d top left and %dx%d bottom right\n", rect.Left, rect.Top, rect.Right, rect.Bottom)
-
-	// Redraw the window
-	if !win.RedrawWindow(hwnd, nil, 0, win.RDW_INVALIDATE|win.RDW_UPDATENOW|win.RDW_FRAME) {
-		return fmt.Errorf("RedrawWindow failed: %w", syscall.GetLastError())
-	}
-	/*
-		if !win.SetWindowPos(hwnd, win.HWND_NOTOPMOST, rect.Left, rect.Top,
-			rect.Right-rect.Left, rect.Bottom-rect.Top,
-			win.SWP_FRAMECHANGED|win.SWP_NOOWNERZORDER|win.SWP_NOZORDER) {
-			return fmt.Errorf("SetWindowPos failed: %w", syscall.GetLastError())
-		} */
-
-	return nil
+	return rect, nil
 }
 
 func StringToUTF16Ptr(s string) *uint16 {
@@ -507,7 +668,7 @@ func updateSave() error {
 func hwndByPID(pid int) (windows.HWND, error) {
 	hwnd := getHWNDFromPID(uint32(pid))
 	if hwnd == 0 {
-		return 0, fmt.Errorf("failed to find hwnd for pid %d", pid)
+		return 0, winerr.New(winerr.ErrNoWindow, fmt.Sprintf("find window of pid %d", pid), nil)
 	}
 	return windows.HWND(hwnd), nil
 }
@@ -531,20 +692,51 @@ func pidByHWND(hwnd uintptr) uint32 {
 }
 
 func enumWindows(enumFunc func(h uintptr) bool) {
-	// Wrap Go function in a syscall callback
-	callback := syscall.NewCallback(func(hwnd uintptr, lparam uintptr) uintptr {
-		if enumFunc(hwnd) {
+	// syscall callbacks are never freed, so a single callback is shared and enumerations are serialized
+	enumWindowsMu.Lock()
+	defer enumWindowsMu.Unlock()
+	enumWindowsFunc = enumFunc
+
+	// Call EnumWindows
+	enumWindowsProc.Call(enumWindowsCallbackPtr, 0)
+}
+
+var (
+	enumWindowsMu          sync.Mutex
+	enumWindowsFunc        func(h uintptr) bool
+	enumWindowsCallbackPtr = syscall.NewCallback(func(hwnd uintptr, lparam uintptr) uintptr {
+		if enumWindowsFunc(hwnd) {
 			return 1 // Continue enumeration
 		}
 		return 0 // Stop enumeration
 	})
-
-	// Call EnumWindows
-	enumWindowsProc.Call(callback, 0)
-}
+)
 
 type enumWindowsCallback func(h uintptr) bool
 
+// errorBox reports a failed action, adding what the user can do about window errors
+func errorBox(action string, err error) {
+	msg := action + ": " + err.Error()
+	if hint := winerr.Hint(err); hint != "" {
+		msg += "\n\n" + hint
+	}
+	_, isElevated := ownIntegrity()
+	if !errors.Is(err, winerr.ErrAccessDenied) || isElevated {
+		walk.MsgBox(nil, "Error", msg, walk.MsgBoxOK)
+		return
+	}
+
+	if walk.MsgBox(nil, "Error", msg+"\n\nRelaunch Shindow as administrator now?", walk.MsgBoxYesNo) != walk.DlgCmdYes {
+		return
+	}
+	err = relaunchElevated()
+	if err != nil {
+		walk.MsgBox(nil, "Error", "Failed to relaunch as administrator: "+err.Error(), walk.MsgBoxOK)
+		return
+	}
+	settingsWnd.Close()
+}
+
 func lastErrorMessage() string {
 	return errorMessage(win.GetLastError())
 }
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/shindow/winstyle"
+	"github.com/xackery/wlk/walk"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	driftCheckInterval = 2 * time.Second
+	// driftReapplyDelay gives a game time to finish its own video mode change before we reapply
+	driftReapplyDelay = 500 * time.Millisecond
+)
+
+// managedClient is a window Shindow has placed and the state it was left in
+type managedClient struct {
+	PID          int
+	HWND         windows.HWND
+	Rect         win.RECT
+	Style        int32
+	ExStyle      int32
+	IsBorderless bool
+	IsBackdrop   bool
+	Rule         *config.Rule
+	Recipe       *config.Recipe  // recipe the window was made borderless with and the bits that count as drift, nil if only moved
+	Profile      *config.Profile // profile the client was launched from, nil if it was started by hand
+	Slot         int             // 1 based layout slot the window was placed into, 0 if none
+
+	OriginalTitle string // title before Shindow renamed the window, restored when it is no longer managed
+	Title         string // title the window was renamed to, empty to leave the game's
+
+	DriftedAt  time.Time // last time the window drifted from what was applied
+	DriftCount int
+	isDrifted  bool // the current drift was already handled
+	isHidden   bool // hidden by hideAll, brought back by restoreAll
+	isNotified bool // a drift notice is open, further drift is only logged until it is closed
+
+	WasMaximized bool // maximized before Shindow first placed it, maximized again when borderless is removed
+}
+
+var (
+	managedMu      sync.Mutex
+	managedClients = map[windows.HWND]*managedClient{}
+	// placingWindows counts the ApplyPlacements calls moving each window. Their location change events arrive
+	// before the new rect is recorded, so drift isn't checked until the placement is done
+	placingWindows = map[windows.HWND]int{}
+	// isDriftSuspended holds off every drift check while the monitors change, see onDisplayChange
+	isDriftSuspended bool
+)
+
+// manageWindow records the style and rect a placed window was left in so drift can be detected,
+// and renames it if its profile or rule has a title template
+func manageWindow(placement *Placement, recipe *config.Recipe) {
+	hwnd := placement.HWND
+	var rect win.RECT
+	if !win.GetWindowRect(hwnd, &rect) {
+		return
+	}
+	pid := int(pidByHWND(uintptr(hwnd)))
+	rule := ruleForWindow(hwnd)
+	title := windowText(hwnd)
+
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	if !ok {
+		client = &managedClient{HWND: hwnd, OriginalTitle: title}
+		managedClients[hwnd] = client
+	}
+	client.PID = pid
+	client.Rect = rect
+	client.Style = win.GetWindowLong(hwnd, win.GWL_STYLE)
+	client.ExStyle = win.GetWindowLong(hwnd, win.GWL_EXSTYLE)
+	client.IsBorderless = placement.IsBorderless
+	client.IsBackdrop = placement.IsBackdrop
+	client.Rule = rule
+	client.Recipe = recipe
+	// a layout applied to a launched client keeps the profile it was launched from
+	if placement.Profile != nil {
+		client.Profile = placement.Profile
+	}
+	if placement.Slot > 0 {
+		client.Slot = placement.Slot
+	}
+	if placement.WasMaximized {
+		client.WasMaximized = true
+	}
+	client.Title = client.title()
+	client.isDrifted = false
+	managedMu.Unlock()
+
+	applyTitle(hwnd)
+}
+
+// beginPlacing stops drift checks on the windows of placements until the returned func is called
+func beginPlacing(placements []*Placement) func() {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	for _, placement := range placements {
+		placingWindows[placement.HWND]++
+	}
+	return func() {
+		managedMu.Lock()
+		defer managedMu.Unlock()
+		for _, placement := range placements {
+			placingWindows[placement.HWND]--
+			if placingWindows[placement.HWND] <= 0 {
+				delete(placingWindows, placement.HWND)
+			}
+		}
+	}
+}
+
+// isDriftChecked returns true unless drift checks on hwnd are held off. managedMu must be held
+func isDriftChecked(hwnd windows.HWND) bool {
+	return !isDriftSuspended && placingWindows[hwnd] == 0
+}
+
+// suspendDrift holds off drift checks on every window until resumeDrift
+func suspendDrift() {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	if !isDriftSuspended {
+		slog.Debug("Suspended drift checks")
+	}
+	isDriftSuspended = true
+}
+
+// resumeDrift checks drift again. Visible windows are first recorded where they are now,
+// so moves made while drift was suspended don't count as drift
+func resumeDrift() {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	for hwnd, client := range managedClients {
+		var rect win.RECT
+		if client.isHidden || win.IsIconic(hwnd) || !win.IsWindowVisible(hwnd) || !win.GetWindowRect(hwnd, &rect) {
+			continue
+		}
+		client.Rect = rect
+		client.isDrifted = false
+	}
+	isDriftSuspended = false
+	slog.Debug("Resumed drift checks")
+}
+
+// unmanageWindow stops watching a window for drift and gives it back its title
+func unmanageWindow(hwnd windows.HWND) {
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	delete(managedClients, hwnd)
+	managedMu.Unlock()
+	if ok {
+		restoreTitle(client)
+	}
+	settingsWnd.Synchronize(updateBackdrops)
+}
+
+// managedWindows returns the handle of every managed window
+func managedWindows() []windows.HWND {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	hwnds := make([]windows.HWND, 0, len(managedClients))
+	for hwnd := range managedClients {
+		hwnds = append(hwnds, hwnd)
+	}
+	return hwnds
+}
+
+// backdropClients returns the managed windows that want a backdrop
+func backdropClients() map[windows.HWND]bool {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	clients := map[windows.HWND]bool{}
+	for hwnd, client := range managedClients {
+		if client.IsBackdrop {
+			clients[hwnd] = true
+		}
+	}
+	return clients
+}
+
+// placement returns the placement that puts the window back the way it was left
+func (c *managedClient) placement() *Placement {
+	return &Placement{HWND: c.HWND, Rect: c.Rect, IsBorderless: c.IsBorderless, Recipe: c.Recipe, IsBackdrop: c.IsBackdrop,
+		Profile: c.Profile, Slot: c.Slot}
+}
+
+// drift describes how a window differs from what was applied, or returns empty if it doesn't
+func (c *managedClient) drift() string {
+	var reasons []string
+	// windows that were only moved have no recipe, so only their rect can drift
+	if c.Recipe != nil {
+		style := win.GetWindowLong(c.HWND, win.GWL_STYLE)
+		mask := int32(c.Recipe.StyleMask())
+		if diff := styleDrift(winstyle.Styles, c.Style&mask, style&mask); diff != "" {
+			reasons = append(reasons, "style "+diff)
+		}
+		exStyle := win.GetWindowLong(c.HWND, win.GWL_EXSTYLE)
+		exMask := int32(c.Recipe.ExStyleMask())
+		if diff := styleDrift(winstyle.ExStyles, c.ExStyle&exMask, exStyle&exMask); diff != "" {
+			reasons = append(reasons, "ex-style "+diff)
+		}
+	}
+	var rect win.RECT
+	if win.GetWindowRect(c.HWND, &rect) && rect != c.Rect {
+		reasons = append(reasons, fmt.Sprintf("rect %d,%d %dx%d changed to %d,%d %dx%d",
+			c.Rect.Left, c.Rect.Top, c.Rect.Right-c.Rect.Left, c.Rect.Bottom-c.Rect.Top,
+			rect.Left, rect.Top, rect.Right-rect.Left, rect.Bottom-rect.Top))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// styleDrift names the flags gained and lost between two masks, or returns empty if they match
+func styleDrift(table []winstyle.Flag, applied int32, current int32) string {
+	var diffs []string
+	gained := uint32(current &^ applied)
+	if gained != 0 {
+		diffs = append(diffs, "gained "+winstyle.Format(table, gained))
+	}
+	lost := uint32(applied &^ current)
+	if lost != 0 {
+		diffs = append(diffs, "lost "+winstyle.Format(table, lost))
+	}
+	return strings.Join(diffs, " and ")
+}
+
+// ruleForWindow returns the rule matching a window's title and exe. Renamed windows are matched by their original title
+func ruleForWindow(hwnd windows.HWND) *config.Rule {
+	pid := int(pidByHWND(uintptr(hwnd)))
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	managedMu.Unlock()
+	if ok {
+		return cfg.MatchRule(client.OriginalTitle, processExe(pid))
+	}
+	return cfg.MatchRule(windowText(hwnd), processExe(pid))
+}
+
+// windowRecipe returns the named recipe, or the recipe of the window's rule if name is empty,
+// with the rule's own style changes merged in
+func windowRecipe(hwnd windows.HWND, name string) *config.Recipe {
+	rule := ruleForWindow(hwnd)
+	if name == "" {
+		name = rule.Recipe
+	}
+	return rule.Adjust(cfg.Recipe(name))
+}
+
+// managedRecipe returns the recipe a managed window was made borderless with, or the one it would be made borderless with
+func managedRecipe(hwnd windows.HWND) *config.Recipe {
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	managedMu.Unlock()
+	if ok && client.Recipe != nil {
+		return client.Recipe
+	}
+	return windowRecipe(hwnd, "")
+}
+
+// wasMaximized returns true if a managed window was maximized before Shindow placed it
+func wasMaximized(hwnd windows.HWND) bool {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	client, ok := managedClients[hwnd]
+	return ok && client.WasMaximized
+}
+
+// checkDrift compares a managed window with what was applied and reacts according to its rule's drift policy
+func checkDrift(hwnd windows.HWND) {
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	if !ok {
+		managedMu.Unlock()
+		return
+	}
+	if !windows.IsWindow(hwnd) {
+		delete(managedClients, hwnd)
+		managedMu.Unlock()
+		return
+	}
+	// minimized windows report an off-screen rect, and hidden ones are put back into place when they are restored
+	if win.IsIconic(hwnd) || !win.IsWindowVisible(hwnd) {
+		managedMu.Unlock()
+		return
+	}
+	if !isDriftChecked(hwnd) {
+		managedMu.Unlock()
+		return
+	}
+	reason := client.drift()
+	if reason == "" || client.isDrifted {
+		client.isDrifted = reason != ""
+		managedMu.Unlock()
+		return
+	}
+	client.isDrifted = true
+	client.DriftedAt = time.Now()
+	client.DriftCount++
+	pid := client.PID
+	policy := client.Rule.DriftPolicy
+	isNotice := policy == config.DriftPolicyNotify && !client.isNotified
+	if isNotice {
+		client.isNotified = true
+	}
+	managedMu.Unlock()
+
+	slog.Warn("Window drifted", append(windowAttrs(hwnd), "drift", reason, "policy", policy)...)
+
+	switch policy {
+	case config.DriftPolicyReapply:
+		time.AfterFunc(driftReapplyDelay, func() {
+			reapplyDrifted(hwnd)
+		})
+	case config.DriftPolicyNotify:
+		if !isNotice {
+			return
+		}
+		settingsWnd.Synchronize(func() {
+			walk.MsgBox(nil, "Window changed", fmt.Sprintf("EverQuest (pid %d) reset its window: %s.\nPress Set Fullscreen Borderless to apply it again.", pid, reason), walk.MsgBoxOK)
+			managedMu.Lock()
+			defer managedMu.Unlock()
+			if client, ok := managedClients[hwnd]; ok {
+				client.isNotified = false
+			}
+		})
+	}
+}
+
+// reapplyDrifted puts a drifted window back the way it was left, unless it was placed again, moved back,
+// minimized or closed since it drifted
+func reapplyDrifted(hwnd windows.HWND) {
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	if !ok || !isDriftChecked(hwnd) || !windows.IsWindow(hwnd) || win.IsIconic(hwnd) || !win.IsWindowVisible(hwnd) {
+		managedMu.Unlock()
+		return
+	}
+	reason := client.drift()
+	placement := client.placement()
+	managedMu.Unlock()
+	if reason == "" {
+		return
+	}
+
+	slog.Info("Reapplying drifted window", append(windowAttrs(hwnd), "drift", reason)...)
+	err := ApplyPlacements([]*Placement{placement})
+	if err != nil {
+		slog.Error("Failed to reapply window", append(windowAttrs(hwnd), "error", err)...)
+	}
+}
+
+// driftLoop periodically checks managed windows for style changes that don't move the window, until done is closed
+func driftLoop(done chan struct{}) {
+	ticker := time.NewTicker(driftCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		for _, hwnd := range managedWindows() {
+			checkDrift(hwnd)
+		}
+		// catch clients that closed, minimized or moved to another monitor
+		settingsWnd.Synchronize(updateBackdrops)
+	}
+}
+
+// slotPlacements returns what was applied to every managed client placed into a layout slot
+func slotPlacements() []*Placement {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	var placements []*Placement
+	for _, client := range managedClients {
+		if client.Slot == 0 || client.isHidden {
+			continue
+		}
+		placements = append(placements, client.placement())
+	}
+	return placements
+}
+
+// managedMembership returns the layout slot and profile name of a managed client's window, used to match groups.
+// Both are empty if the client is not managed
+func managedMembership(pid int) (int, string) {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	for _, client := range managedClients {
+		if client.PID != pid {
+			continue
+		}
+		profile := ""
+		if client.Profile != nil {
+			profile = client.Profile.Name
+		}
+		return client.Slot, profile
+	}
+	return 0, ""
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/xackery/shindow/fit"
+	"github.com/xackery/wlk/win"
+)
+
+var (
+	enumDisplayMonitorsProc = user32.NewProc("EnumDisplayMonitors")
+
+	enumMonitorsMu          sync.Mutex
+	enumMonitorsResult      []win.HMONITOR
+	enumMonitorsCallbackPtr = syscall.NewCallback(func(hmon uintptr, hdc uintptr, rect uintptr, lparam uintptr) uintptr {
+		enumMonitorsResult = append(enumMonitorsResult, win.HMONITOR(hmon))
+		return 1 // Continue enumeration
+	})
+)
+
+// monitors returns every display monitor in enumeration order
+func monitors() []win.HMONITOR {
+	enumMonitorsMu.Lock()
+	defer enumMonitorsMu.Unlock()
+	enumMonitorsResult = nil
+	enumDisplayMonitorsProc.Call(0, 0, enumMonitorsCallbackPtr, 0)
+	return enumMonitorsResult
+}
+
+// monitorNumber returns the 1 based number of a monitor in enumeration order, or 0 if it is not found
+func monitorNumber(hmon win.HMONITOR) int {
+	for i, m := range monitors() {
+		if m == hmon {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// monitorInfo returns the full and work area rects of a monitor
+func monitorInfo(hmon win.HMONITOR) (win.MONITORINFO, bool) {
+	var info win.MONITORINFO
+	info.CbSize = uint32(unsafe.Sizeof(info))
+	ok := win.GetMonitorInfo(hmon, &info)
+	return info, ok
+}
+
+// monitorRects returns the full rect of every display monitor in enumeration order, as layouts resolve spans from
+func monitorRects() []fit.Rect {
+	var rects []fit.Rect
+	for _, hmon := range monitors() {
+		info, ok := monitorInfo(hmon)
+		if !ok {
+			continue
+		}
+		rects = append(rects, fitRect(info.RcMonitor))
+	}
+	return rects
+}
+
+// fitRect converts a window rect to a fit rect
+func fitRect(rect win.RECT) fit.Rect {
+	return fit.Rect{X: int(rect.Left), Y: int(rect.Top), W: int(rect.Right - rect.Left), H: int(rect.Bottom - rect.Top)}
+}
+
+// windowRect converts a fit rect to a window rect
+func windowRect(rect fit.Rect) win.RECT {
+	return win.RECT{Left: int32(rect.X), Top: int32(rect.Y), Right: int32(rect.X + rect.W), Bottom: int32(rect.Y + rect.H)}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/xackery/shindow/config"
+	"github.com/xackery/shindow/winerr"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+// Placement is a window and the rect it should be moved to
+type Placement struct {
+	HWND         windows.HWND
+	Rect         win.RECT
+	IsBorderless bool            // strip the frame before moving, otherwise the style is left as is
+	Recipe       *config.Recipe  // recipe used to strip the frame, nil for the window's rule recipe
+	IsBackdrop   bool            // show the backdrop beneath the window on its monitor
+	Profile      *config.Profile // profile the client was launched from, nil if unknown
+	Slot         int             // 1 based layout slot, 0 if none
+	WasMaximized bool            // set by ApplyPlacements when it restored a maximized window its rule remembers
+}
+
+// windowState is a snapshot of a window used to roll back a failed placement
+type windowState struct {
+	hwnd        windows.HWND
+	style       int32
+	exStyle     int32
+	rect        win.RECT
+	isMaximized bool             // maximized when it was captured, maximized again on rollback
+	placement   *windowPlacement // normal rect and show state of a maximized window
+}
+
+// windowPlacement is a WINDOWPLACEMENT
+type windowPlacement struct {
+	Length           uint32
+	Flags            uint32
+	ShowCmd          uint32
+	PtMinPosition    win.POINT
+	PtMaxPosition    win.POINT
+	RcNormalPosition win.RECT
+}
+
+var (
+	getWindowPlacementProc = user32.NewProc("GetWindowPlacement")
+	setWindowPlacementProc = user32.NewProc("SetWindowPlacement")
+)
+
+const (
+	// maximizedSettleTimeout is how long a restored window gets to stop changing its rect
+	maximizedSettleTimeout = time.Second
+	maximizedSettlePoll    = 20 * time.Millisecond
+)
+
+// ApplyPlacements moves every window to its rect in a single DeferWindowPos transaction,
+// so a whole layout is committed with one repaint per window. If any window rejects the move,
+// every window is rolled back to the style and rect it had before
+func ApplyPlacements(placements []*Placement) error {
+	if len(placements) == 0 {
+		return nil
+	}
+	// failures are read from the per thread last error, and this runs off the GUI thread for drift and launches
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for _, placement := range placements {
+		wakeWindow(placement.HWND)
+		rect := placement.Rect
+		if rect.Right <= rect.Left || rect.Bottom <= rect.Top {
+			return winerr.New(winerr.ErrInvalidRect, fmt.Sprintf("place window %d", placement.HWND), fmt.Errorf("%s is empty", rectString(rect)))
+		}
+		if win.IsZoomed(placement.HWND) && ruleForWindow(placement.HWND).Maximized == config.MaximizedReject {
+			return winerr.New(winerr.ErrMaximized, fmt.Sprintf("place window %d", placement.HWND), nil)
+		}
+		err := checkAccess(placement.HWND)
+		if err != nil {
+			return err
+		}
+	}
+	defer beginPlacing(placements)()
+
+	// windows are captured before maximized ones are restored, so rollback maximizes them again even if restoring fails
+	states := make([]*windowState, 0, len(placements))
+	var maximized []windows.HWND
+	for _, placement := range placements {
+		state, err := captureWindowState(placement.HWND)
+		if err != nil {
+			return rollbackPlacements(states, err)
+		}
+		states = append(states, state)
+		if state.isMaximized {
+			maximized = append(maximized, placement.HWND)
+			placement.WasMaximized = ruleForWindow(placement.HWND).Maximized == config.MaximizedRemember
+		}
+	}
+	// a maximized window ignores any rect it is given, so every one is restored first
+	err := restoreMaximized(maximized)
+	if err != nil {
+		return rollbackPlacements(states, err)
+	}
+
+	recipes := make([]*config.Recipe, len(placements))
+	for i, placement := range placements {
+		recipes[i] = placement.Recipe
+		if placement.IsBorderless && recipes[i] == nil {
+			recipes[i] = windowRecipe(placement.HWND, "")
+		}
+	}
+
+	for i, placement := range placements {
+		if !placement.IsBorderless {
+			continue
+		}
+		err := setBorderlessStyle(placement.HWND, recipes[i], true)
+		if err != nil {
+			return rollbackPlacements(states, err)
+		}
+	}
+
+	hdwp := win.BeginDeferWindowPos(int32(len(placements)))
+	if hdwp == 0 {
+		return rollbackPlacements(states, fmt.Errorf("BeginDeferWindowPos failed: %w", syscall.GetLastError()))
+	}
+
+	for i, placement := range placements {
+		rect := placement.Rect
+		insertAfter, flags := placementFlags(recipes[i])
+		// on failure the system has already freed the whole transaction
+		hdwp = win.DeferWindowPos(hdwp, placement.HWND, insertAfter, rect.Left, rect.Top,
+			rect.Right-rect.Left, rect.Bottom-rect.Top, flags)
+		if hdwp == 0 {
+			return rollbackPlacements(states, winerr.New(winerr.ErrInvalidRect, fmt.Sprintf("DeferWindowPos window %d to %s", placement.HWND, rectString(rect)), syscall.GetLastError()))
+		}
+	}
+
+	if !win.EndDeferWindowPos(hdwp) {
+		return rollbackPlacements(states, winerr.New(winerr.ErrInvalidRect, "EndDeferWindowPos", syscall.GetLastError()))
+	}
+
+	for i, placement := range placements {
+		slog.Info("Placed window", append(windowAttrs(placement.HWND), "before", rectString(states[i].rect), "after", rectString(placement.Rect), "borderless", placement.IsBorderless)...)
+
+		// the move is already committed, so a window that won't repaint is still tracked
+		if !win.RedrawWindow(placement.HWND, nil, 0, win.RDW_INVALIDATE|win.RDW_UPDATENOW|win.RDW_FRAME) {
+			slog.Warn("Failed to redraw window", append(windowAttrs(placement.HWND), "error", syscall.GetLastError())...)
+		}
+		manageWindow(placement, recipes[i])
+	}
+	settingsWnd.Synchronize(updateBackdrops)
+	return nil
+}
+
+// placementFlags returns the z-order and SetWindowPos flags a recipe asks for. A nil recipe keeps the z-order
+func placementFlags(recipe *config.Recipe) (windows.HWND, uint32) {
+	if recipe == nil {
+		return 0, win.SWP_FRAMECHANGED | win.SWP_NOOWNERZORDER | win.SWP_NOZORDER
+	}
+
+	flags := uint32(win.SWP_NOOWNERZORDER)
+	if recipe.FrameChanged {
+		flags |= win.SWP_FRAMECHANGED
+	}
+	switch recipe.ZOrder {
+	case config.ZOrderTop:
+		return win.HWND_TOP, flags
+	case config.ZOrderBottom:
+		return win.HWND_BOTTOM, flags
+	case config.ZOrderTopmost:
+		return win.HWND_TOPMOST, flags
+	case config.ZOrderNoTopmost:
+		return win.HWND_NOTOPMOST, flags
+	}
+	return 0, flags | win.SWP_NOZORDER
+}
+
+// restoreMaximized restores maximized windows and waits for their rects to settle. They are all restored
+// before waiting, so any number of them takes no longer than maximizedSettleTimeout
+func restoreMaximized(hwnds []windows.HWND) error {
+	if len(hwnds) == 0 {
+		return nil
+	}
+	for _, hwnd := range hwnds {
+		win.ShowWindow(hwnd, win.SW_RESTORE)
+	}
+
+	last := make([]win.RECT, len(hwnds))
+	isSettled := make([]bool, len(hwnds))
+	deadline := time.Now().Add(maximizedSettleTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(maximizedSettlePoll)
+		pending := 0
+		for i, hwnd := range hwnds {
+			if isSettled[i] {
+				continue
+			}
+			var rect win.RECT
+			if !win.IsZoomed(hwnd) && win.GetWindowRect(hwnd, &rect) && rect == last[i] {
+				isSettled[i] = true
+				slog.Info("Restored maximized window", append(windowAttrs(hwnd), "rect", rectString(rect))...)
+				continue
+			}
+			last[i] = rect
+			pending++
+		}
+		if pending == 0 {
+			return nil
+		}
+	}
+
+	var errs []error
+	for i, hwnd := range hwnds {
+		if !isSettled[i] {
+			errs = append(errs, winerr.New(winerr.ErrMaximized, fmt.Sprintf("restore window %d", hwnd), fmt.Errorf("still changing after %s", maximizedSettleTimeout)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// captureWindowState records the style and rect of a window, and the normal rect of a maximized one
+func captureWindowState(hwnd windows.HWND) (*windowState, error) {
+	state := &windowState{
+		hwnd:        hwnd,
+		style:       win.GetWindowLong(hwnd, win.GWL_STYLE),
+		exStyle:     win.GetWindowLong(hwnd, win.GWL_EXSTYLE),
+		isMaximized: win.IsZoomed(hwnd),
+	}
+	if !win.GetWindowRect(hwnd, &state.rect) {
+		return nil, winerr.New(winerr.ErrNoWindow, fmt.Sprintf("GetWindowRect window %d", hwnd), syscall.GetLastError())
+	}
+	if state.isMaximized {
+		placement := &windowPlacement{}
+		placement.Length = uint32(unsafe.Sizeof(*placement))
+		ret, _, err := getWindowPlacementProc.Call(uintptr(hwnd), uintptr(unsafe.Pointer(placement)))
+		if ret == 0 {
+			return nil, winerr.New(winerr.ErrNoWindow, fmt.Sprintf("GetWindowPlacement window %d", hwnd), err)
+		}
+		state.placement = placement
+	}
+	return state, nil
+}
+
+// restore puts a window back to the captured style and rect. Every step is tried, and the failed ones are joined
+func (s *windowState) restore() error {
+	var errs []error
+	err := setWindowLong(s.hwnd, win.GWL_STYLE, s.style)
+	if err != nil {
+		errs = append(errs, winerr.New(winerr.ErrStyleRejected, "SetWindowLong style", err))
+	}
+	err = setWindowLong(s.hwnd, win.GWL_EXSTYLE, s.exStyle)
+	if err != nil {
+		errs = append(errs, winerr.New(winerr.ErrStyleRejected, "SetWindowLong ex-style", err))
+	}
+	if s.placement != nil {
+		// the placement puts back the normal rect the window restores to, as well as maximizing it
+		if !win.SetWindowPos(s.hwnd, 0, 0, 0, 0, 0, win.SWP_FRAMECHANGED|win.SWP_NOMOVE|win.SWP_NOSIZE|win.SWP_NOOWNERZORDER|win.SWP_NOZORDER) {
+			errs = append(errs, fmt.Errorf("SetWindowPos failed: %w", syscall.GetLastError()))
+		}
+		ret, _, err := setWindowPlacementProc.Call(uintptr(s.hwnd), uintptr(unsafe.Pointer(s.placement)))
+		if ret == 0 {
+			errs = append(errs, fmt.Errorf("SetWindowPlacement failed: %w", err))
+		}
+		return errors.Join(errs...)
+	}
+	if !win.SetWindowPos(s.hwnd, 0, s.rect.Left, s.rect.Top,
+		s.rect.Right-s.rect.Left, s.rect.Bottom-s.rect.Top,
+		win.SWP_FRAMECHANGED|win.SWP_NOOWNERZORDER|win.SWP_NOZORDER) {
+		errs = append(errs, fmt.Errorf("SetWindowPos failed: %w", syscall.GetLastError()))
+	}
+	if s.isMaximized {
+		win.ShowWindow(s.hwnd, win.SW_MAXIMIZE)
+	}
+	return errors.Join(errs...)
+}
+
+// rollbackPlacements restores every captured window and returns the cause, joined with any rollback failures
+func rollbackPlacements(states []*windowState, cause error) error {
+	slog.Error("Placement failed, rolling back", "windows", len(states), "error", cause)
+	errs := []error{cause}
+	for _, state := range states {
+		err := state.restore()
+		if err != nil {
+			slog.Error("Failed to roll back window", append(windowAttrs(state.hwnd), "rect", rectString(state.rect), "error", err)...)
+			errs = append(errs, fmt.Errorf("rollback window %d: %w", state.hwnd, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+	"github.com/xackery/wlk/walk"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	processRefreshInterval = 2 * time.Second
+	processChangeHighlight = 10 * time.Second
+)
+
+// process table columns
+const (
+	processColumnName = iota
+	processColumnPID
+	processColumnTitle
+	processColumnHWND
+	processColumnRect
+	processColumnBorderless
+	processColumnMonitor
+	processColumnStarted
+	processColumnCPU
+	processColumnMemory
+	processColumnExe
+)
+
+var (
+	// processCache keeps gopsutil processes between refreshes so CPU usage can be measured since the last one
+	processCacheMu sync.Mutex
+	processCache   = map[int32]*process.Process{}
+)
+
+// ProcessModel represents a model for processes
+type ProcessModel struct {
+	walk.TableModelBase
+	entries []*ProcessEntry
+}
+
+// ProcessEntry is an entry for a client process and the state of its main window
+type ProcessEntry struct {
+	Name         string
+	PID          int
+	Title        string
+	HWND         windows.HWND
+	Rect         win.RECT
+	IsBorderless bool
+	Monitor      int // 1 based monitor number, 0 if unknown
+	StartTime    time.Time
+	ExePath      string
+	CPU          float64 // percent used since the previous refresh
+	Memory       uint64  // resident bytes
+
+	ChangedAt      time.Time // last time the window state differed from the previous refresh
+	LostBorderless bool      // the window was borderless and got its frame back
+}
+
+// Set sets the items, flagging window state that changed since the previous set
+func (m *ProcessModel) Set(items []*ProcessEntry) {
+	previous := map[int]*ProcessEntry{}
+	for _, entry := range m.entries {
+		previous[entry.PID] = entry
+	}
+	for _, entry := range items {
+		prev, ok := previous[entry.PID]
+		if !ok {
+			continue
+		}
+		entry.ChangedAt = prev.ChangedAt
+		entry.LostBorderless = prev.LostBorderless
+		if prev.HWND != entry.HWND || prev.Rect != entry.Rect || prev.IsBorderless != entry.IsBorderless {
+			entry.ChangedAt = time.Now()
+		}
+		if prev.IsBorderless && !entry.IsBorderless {
+			entry.LostBorderless = true
+		}
+		if entry.IsBorderless {
+			entry.LostBorderless = false
+		}
+	}
+	m.entries = items
+	m.PublishRowsReset()
+}
+
+// ItemCount returns the number of items
+func (m *ProcessModel) ItemCount() int {
+	return len(m.entries)
+}
+
+// RowCount returns the number of rows
+func (m *ProcessModel) RowCount() int {
+	return len(m.entries)
+}
+
+// Value returns the value of a cell
+func (m *ProcessModel) Value(row, col int) interface{} {
+	if row < 0 || row >= len(m.entries) {
+		return nil
+	}
+	entry := m.entries[row]
+	switch col {
+	case processColumnName:
+		return entry.Name
+	case processColumnPID:
+		return entry.PID
+	case processColumnTitle:
+		return entry.Title
+	case processColumnHWND:
+		if entry.HWND == 0 {
+			return ""
+		}
+		return fmt.Sprintf("0x%X", entry.HWND)
+	case processColumnRect:
+		if entry.HWND == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d,%d %dx%d", entry.Rect.Left, entry.Rect.Top, entry.Rect.Right-entry.Rect.Left, entry.Rect.Bottom-entry.Rect.Top)
+	case processColumnBorderless:
+		if entry.HWND == 0 {
+			return ""
+		}
+		if entry.IsBorderless {
+			return "yes"
+		}
+		if entry.LostBorderless {
+			return "lost"
+		}
+		return "no"
+	case processColumnMonitor:
+		if entry.Monitor == 0 {
+			return ""
+		}
+		return entry.Monitor
+	case processColumnStarted:
+		if entry.StartTime.IsZero() {
+			return ""
+		}
+		return entry.StartTime.Format("15:04:05")
+	case processColumnCPU:
+		return fmt.Sprintf("%.1f%%", entry.CPU)
+	case processColumnMemory:
+		return fmt.Sprintf("%d MB", entry.Memory/1024/1024)
+	case processColumnExe:
+		return entry.ExePath
+	}
+	return nil
+}
+
+// StyleCell highlights clients whose window changed recently or lost its borderless style
+func (m *ProcessModel) StyleCell(style *walk.CellStyle) {
+	row := style.Row()
+	if row < 0 || row >= len(m.entries) {
+		return
+	}
+	entry := m.entries[row]
+	if entry.LostBorderless {
+		style.BackgroundColor = walk.RGB(255, 200, 200)
+		return
+	}
+	if !entry.ChangedAt.IsZero() && time.Since(entry.ChangedAt) < processChangeHighlight {
+		style.BackgroundColor = walk.RGB(255, 245, 190)
+	}
+}
+
+func (m *ProcessModel) PID(index int) int {
+	if index < 0 || index >= len(m.entries) {
+		return 0
+	}
+	return m.entries[index].PID
+}
+
+// Index returns the row of a PID, or -1 if it is not listed
+func (m *ProcessModel) Index(pid int) int {
+	for i, entry := range m.entries {
+		if entry.PID == pid {
+			return i
+		}
+	}
+	return -1
+}
+
+// PIDs returns the PID of every entry in list order
+func (m *ProcessModel) PIDs() []int {
+	pids := make([]int, 0, len(m.entries))
+	for _, entry := range m.entries {
+		pids = append(pids, entry.PID)
+	}
+	return pids
+}
+
+// Entry returns the entry of a PID, or nil if it is not listed
+func (m *ProcessModel) Entry(pid int) *ProcessEntry {
+	index := m.Index(pid)
+	if index == -1 {
+		return nil
+	}
+	return m.entries[index]
+}
+
+func (m *ProcessModel) SelectedProcess() int {
+	if tblProcesses.CurrentIndex() == -1 {
+		return 0
+	}
+	return m.PID(tblProcesses.CurrentIndex())
+}
+
+// SelectedProcesses returns the PID of every selected row in list order
+func (m *ProcessModel) SelectedProcesses() []int {
+	indexes := append([]int{}, tblProcesses.SelectedIndexes()...)
+	sort.Ints(indexes)
+	var pids []int
+	for _, index := range indexes {
+		if pid := m.PID(index); pid != 0 {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// setProcesses refreshes the process table, keeping the current row and selection
+func setProcesses(processes []*ProcessEntry) {
+	pid := lstDevicesModel.SelectedProcess()
+	selected := lstDevicesModel.SelectedProcesses()
+	lstDevicesModel.Set(processes)
+	index := lstDevicesModel.Index(pid)
+	if index == -1 && lstDevicesModel.ItemCount() == 1 {
+		index = 0
+	}
+	if index != -1 {
+		tblProcesses.SetCurrentIndex(index)
+	}
+	var indexes []int
+	for _, pid := range selected {
+		if i := lstDevicesModel.Index(pid); i != -1 {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) > 1 {
+		tblProcesses.SetSelectedIndexes(indexes)
+	}
+}
+
+// refreshProcessesLoop periodically refreshes the process table until done is closed
+func refreshProcessesLoop(done chan struct{}) {
+	ticker := time.NewTicker(processRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		processes, err := listProcesses()
+		if err != nil {
+			slog.Error("Failed to list processes", "error", err)
+			continue
+		}
+		tuneClients(processes)
+		settingsWnd.Synchronize(func() {
+			setProcesses(processes)
+		})
+	}
+}
+
+func listProcesses() ([]*ProcessEntry, error) {
+	var processes []*ProcessEntry
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("processes: %w", err)
+	}
+
+	processCacheMu.Lock()
+	defer processCacheMu.Unlock()
+
+	seen := map[int32]bool{}
+	for _, proc := range procs {
+
+		name, err := proc.Name()
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(name, "eqgame.exe") {
+			continue
+		}
+		cached, ok := processCache[proc.Pid]
+		if !ok {
+			cached = proc
+			processCache[proc.Pid] = proc
+		}
+		seen[proc.Pid] = true
+
+		entry := &ProcessEntry{
+			Name: name,
+			PID:  int(proc.Pid),
+		}
+		entry.fill(cached)
+		processes = append(processes, entry)
+	}
+
+	for pid := range processCache {
+		if !seen[pid] {
+			delete(processCache, pid)
+		}
+	}
+	return processes, nil
+}
+
+// fill populates the process and window details of an entry. Details that can't be read are left empty
+func (e *ProcessEntry) fill(proc *process.Process) {
+	exe, err := proc.Exe()
+	if err == nil {
+		e.ExePath = exe
+	}
+	created, err := proc.CreateTime()
+	if err == nil {
+		e.StartTime = time.UnixMilli(created)
+	}
+	cpu, err := proc.Percent(0)
+	if err == nil {
+		e.CPU = cpu
+	}
+	mem, err := proc.MemoryInfo()
+	if err == nil {
+		e.Memory = mem.RSS
+	}
+
+	hwnd, err := hwndByPID(e.PID)
+	if err != nil {
+		return
+	}
+	e.HWND = hwnd
+	e.Title = windowText(hwnd)
+	e.IsBorderless = isBorderlessStyle(win.GetWindowLong(hwnd, win.GWL_STYLE))
+	win.GetWindowRect(hwnd, &e.Rect)
+	e.Monitor = monitorNumber(win.MonitorFromWindow(hwnd, win.MONITOR_DEFAULTTONEAREST))
+}
+
+// processExe returns the exe path of a process, or empty if it can't be read
+func processExe(pid int) string {
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return ""
+	}
+	exe, err := proc.Exe()
+	if err != nil {
+		return ""
+	}
+	return exe
+}
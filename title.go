@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var setWindowTextProc = user32.NewProc("SetWindowTextW")
+
+// title expands the title template of the client's profile or rule, or returns empty if neither has one.
+// Templates may use {char}, {server}, {slot}, {profile}, {pid} and {title} for the game's own title
+func (c *managedClient) title() string {
+	template := c.Rule.Title
+	var char, server, profile string
+	if c.Profile != nil {
+		if c.Profile.Title != "" {
+			template = c.Profile.Title
+		}
+		char = c.Profile.Char
+		server = c.Profile.Server
+		profile = c.Profile.Name
+	}
+	if template == "" {
+		return ""
+	}
+	slot := ""
+	if c.Slot > 0 {
+		slot = strconv.Itoa(c.Slot)
+	}
+	return strings.NewReplacer(
+		"{char}", char,
+		"{server}", server,
+		"{slot}", slot,
+		"{profile}", profile,
+		"{pid}", strconv.Itoa(c.PID),
+		"{title}", c.OriginalTitle,
+	).Replace(template)
+}
+
+// setWindowText sets the title of a window
+func setWindowText(hwnd windows.HWND, text string) error {
+	wakeWindow(hwnd)
+	ret, _, err := setWindowTextProc.Call(uintptr(hwnd), uintptr(unsafe.Pointer(StringToUTF16Ptr(text))))
+	if ret == 0 {
+		return fmt.Errorf("SetWindowText: %w", err)
+	}
+	return nil
+}
+
+// applyTitle renames a managed window to its title template if the game has set its own title since.
+// WM_SETTEXT waits on the game, so the rename happens off the calling thread
+func applyTitle(hwnd windows.HWND) {
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	if !ok || client.Title == "" {
+		managedMu.Unlock()
+		return
+	}
+	title := client.Title
+	managedMu.Unlock()
+
+	go func() {
+		if windowText(hwnd) == title {
+			return
+		}
+		err := setWindowText(hwnd, title)
+		if err != nil {
+			slog.Error("Failed to set window title", append(windowAttrs(hwnd), "title", title, "error", err)...)
+			return
+		}
+		slog.Debug("Set window title", append(windowAttrs(hwnd), "title", title)...)
+	}()
+}
+
+// restoreTitle gives a window back the title it had before it was renamed
+func restoreTitle(client *managedClient) {
+	if client.Title == "" || !windows.IsWindow(client.HWND) {
+		return
+	}
+	err := setWindowText(client.HWND, client.OriginalTitle)
+	if err != nil {
+		slog.Error("Failed to restore window title", append(windowAttrs(client.HWND), "title", client.OriginalTitle, "error", err)...)
+	}
+}
+
+// restoreTitles gives every renamed window back its original title, e.g. when Shindow exits
+func restoreTitles() {
+	managedMu.Lock()
+	clients := make([]*managedClient, 0, len(managedClients))
+	for _, client := range managedClients {
+		clients = append(clients, client)
+	}
+	managedMu.Unlock()
+	for _, client := range clients {
+		restoreTitle(client)
+	}
+}
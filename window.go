@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/xackery/shindow/winerr"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	getWindowTextLengthProc = user32.NewProc("GetWindowTextLengthW")
+	getWindowTextProc       = user32.NewProc("GetWindowTextW")
+	setWindowLongProc       = user32.NewProc("SetWindowLongW")
+	setLastErrorProc        = kernel32.NewProc("SetLastError")
+)
+
+// windowText returns the title of a window
+func windowText(hwnd windows.HWND) string {
+	n, _, _ := getWindowTextLengthProc.Call(uintptr(hwnd))
+	if n == 0 {
+		return ""
+	}
+	buf := make([]uint16, n+1)
+	getWindowTextProc.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return syscall.UTF16ToString(buf)
+}
+
+// setWindowLong sets a window long. SetWindowLong returns the previous value, which can be 0,
+// so the last error is cleared first to tell a failure apart from a success. The last error is
+// per thread, so the goroutine stays on its thread from clearing it to reading it back
+func setWindowLong(hwnd windows.HWND, index int32, value int32) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	setLastErrorProc.Call(0)
+	ret, _, err := setWindowLongProc.Call(uintptr(hwnd), uintptr(index), uintptr(value))
+	if ret != 0 || err == windows.ERROR_SUCCESS {
+		return nil
+	}
+	return err
+}
+
+// showWindow minimizes, restores or hides a window with a SW_* command
+func showWindow(hwnd windows.HWND, cmd int32) error {
+	if !windows.IsWindow(hwnd) {
+		return winerr.New(winerr.ErrNoWindow, fmt.Sprintf("ShowWindow window %d", hwnd), nil)
+	}
+	wakeWindow(hwnd)
+	win.ShowWindow(hwnd, cmd)
+	return nil
+}
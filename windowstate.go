@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/xackery/shindow/ipc"
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+// setWindowState minimizes, restores or hides every managed client
+func setWindowState(state string) error {
+	var fn func(hwnd windows.HWND) error
+	switch state {
+	case ipc.WindowStateMinimize:
+		fn = minimizeWindow
+	case ipc.WindowStateRestore:
+		fn = restoreWindow
+	case ipc.WindowStateHide:
+		fn = hideWindow
+	default:
+		return fmt.Errorf("unknown window state %s, expected minimize, restore or hide", state)
+	}
+
+	hwnds := managedWindows()
+	results := make([]*clientResult, 0, len(hwnds))
+	for _, hwnd := range hwnds {
+		err := fn(hwnd)
+		if err != nil {
+			slog.Error("Failed to "+state+" window", append(windowAttrs(hwnd), "error", err)...)
+		}
+		results = append(results, &clientResult{PID: int(pidByHWND(uintptr(hwnd))), Err: err})
+	}
+	slog.Info("Set window state", "state", state, "clients", len(hwnds))
+	settingsWnd.Synchronize(updateBackdrops)
+	return resultsError(results)
+}
+
+// minimizeWindow minimizes a window. Its managed rect and style are left alone for restoreWindow
+func minimizeWindow(hwnd windows.HWND) error {
+	return showWindow(hwnd, win.SW_MINIMIZE)
+}
+
+// hideWindow hides a window, which also removes its taskbar button
+func hideWindow(hwnd windows.HWND) error {
+	err := showWindow(hwnd, win.SW_HIDE)
+	if err != nil {
+		return err
+	}
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	if client, ok := managedClients[hwnd]; ok {
+		client.isHidden = true
+	}
+	return nil
+}
+
+// restoreWindow shows a minimized or hidden window, then puts a managed window back into its rect
+// with its borderless style, which games often drop while minimized
+func restoreWindow(hwnd windows.HWND) error {
+	err := showWindow(hwnd, win.SW_RESTORE)
+	if err != nil {
+		return err
+	}
+	managedMu.Lock()
+	client, ok := managedClients[hwnd]
+	var placement *Placement
+	if ok {
+		client.isHidden = false
+		placement = client.placement()
+	}
+	managedMu.Unlock()
+	if !ok {
+		return nil
+	}
+	err = ApplyPlacements([]*Placement{placement})
+	if err != nil {
+		return fmt.Errorf("reapply: %w", err)
+	}
+	return nil
+}
+
+// isAnyHidden returns true if hideAll hid a managed client that is still hidden
+func isAnyHidden() bool {
+	managedMu.Lock()
+	defer managedMu.Unlock()
+	for _, client := range managedClients {
+		if client.isHidden {
+			return true
+		}
+	}
+	return false
+}
+
+// runWindowState is the hotkey action of a window state command. It leaves the event thread free,
+// since showing a window waits on the game to handle it
+func runWindowState(state string) func() {
+	return func() {
+		go func() {
+			next := state
+			if next == ipc.WindowStateHide && isAnyHidden() {
+				// the boss key brings everything back when pressed again
+				next = ipc.WindowStateRestore
+			}
+			err := setWindowState(next)
+			if err != nil {
+				slog.Error("Failed to set window state", "state", next, "error", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,139 @@
+// Package winerr classifies failed window operations so the GUI, CLI and IPC layers can tell users what to do about them
+package winerr
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// Win32 error codes that decide the kind of an error regardless of the call that failed
+const (
+	codeAccessDenied        syscall.Errno = 5    // ERROR_ACCESS_DENIED
+	codeInvalidWindowHandle syscall.Errno = 1400 // ERROR_INVALID_WINDOW_HANDLE
+)
+
+var (
+	// ErrNoWindow means a client has no top level window, or it was closed
+	ErrNoWindow = errors.New("no window")
+	// ErrMaximized means a window is maximized and won't keep a rect
+	ErrMaximized = errors.New("window is maximized")
+	// ErrAccessDenied means the client runs at a higher integrity level, e.g. as administrator
+	ErrAccessDenied = errors.New("access denied")
+	// ErrInvalidRect means a rect is empty, unparsable or was refused by the window
+	ErrInvalidRect = errors.New("invalid rect")
+	// ErrStyleRejected means the window refused a style change
+	ErrStyleRejected = errors.New("style rejected")
+)
+
+// kind describes one of the Err values
+type kind struct {
+	err  error
+	name string
+	hint string
+}
+
+var kinds = []*kind{
+	{ErrNoWindow, "no_window", "Make sure the client is running and past the loading screen, then press Refresh"},
+	{ErrMaximized, "maximized", "Restore the window from maximized and try again, or set maximized = restore in its rule"},
+	{ErrAccessDenied, "access_denied", "The client is running as administrator, run Shindow as administrator too"},
+	{ErrInvalidRect, "invalid_rect", "Use a positive width and height that fit on a monitor"},
+	{ErrStyleRejected, "style_rejected", "The client refused the new style, try another recipe for it in shindow.ini"},
+}
+
+// Error is a failed window operation
+type Error struct {
+	Kind error         // one of the Err values
+	Op   string        // what failed, e.g. SetWindowLong style
+	Code syscall.Errno // Win32 error code, 0 if the failure didn't come from a Win32 call
+	Err  error         // cause, may be nil
+}
+
+// New returns an error of kind for op. A Win32 error code in err overrides kind when it
+// means the window is gone or access was denied
+func New(kind error, op string, err error) *Error {
+	e := &Error{Kind: kind, Op: op, Err: err}
+	if !errors.As(err, &e.Code) {
+		return e
+	}
+	switch e.Code {
+	case codeAccessDenied:
+		e.Kind = ErrAccessDenied
+	case codeInvalidWindowHandle:
+		e.Kind = ErrNoWindow
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := e.Op + ": " + e.Kind.Error()
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Code != 0 {
+		msg += fmt.Sprintf(" (code %d)", uint32(e.Code))
+	}
+	return msg
+}
+
+// Unwrap lets errors.Is match both the kind and the cause
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Hint returns what the user can do about the error
+func (e *Error) Hint() string {
+	return Hint(e.Kind)
+}
+
+// Kind returns the Err value err matches, or nil if it isn't a window error
+func Kind(err error) error {
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			return k.err
+		}
+	}
+	return nil
+}
+
+// Name returns a stable name of the kind of err, e.g. no_window, or empty if it isn't a window error
+func Name(err error) string {
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			return k.name
+		}
+	}
+	return ""
+}
+
+// Lookup returns the Err value with a name returned by Name, or nil if unknown
+func Lookup(name string) error {
+	for _, k := range kinds {
+		if k.name == name {
+			return k.err
+		}
+	}
+	return nil
+}
+
+// Hint returns what the user can do about err, or empty if it isn't a window error
+func Hint(err error) string {
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			return k.hint
+		}
+	}
+	return ""
+}
+
+// Code returns the Win32 error code carried by err, or 0 if none
+func Code(err error) uint32 {
+	var e *Error
+	if errors.As(err, &e) {
+		return uint32(e.Code)
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"syscall"
+
+	"github.com/xackery/wlk/win"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	eventSystemForeground     = 0x0003
+	eventSystemMoveSizeStart  = 0x000A
+	eventSystemMoveSizeEnd    = 0x000B
+	eventSystemSwitchStart    = 0x0014
+	eventSystemMinimizeStart  = 0x0016
+	eventObjectLocationChange = 0x800B
+	eventObjectNameChange     = 0x800C
+
+	winEventOutOfContext   = 0x0000
+	winEventSkipOwnProcess = 0x0002
+
+	objidWindow = 0
+)
+
+var (
+	setWinEventHookProc   = user32.NewProc("SetWinEventHook")
+	unhookWinEventProc    = user32.NewProc("UnhookWinEvent")
+	postThreadMessageProc = user32.NewProc("PostThreadMessageW")
+
+	// winEvents are the events hooked by the event thread
+	winEvents = []uint32{
+		eventSystemForeground,
+		eventSystemMoveSizeStart,
+		eventSystemMoveSizeEnd,
+		eventSystemSwitchStart,
+		eventSystemMinimizeStart,
+		eventObjectLocationChange,
+		eventObjectNameChange,
+	}
+
+	winEventCallbackPtr = syscall.NewCallback(func(hook uintptr, event uintptr, hwnd uintptr, idObject uintptr, idChild uintptr, idEventThread uintptr, eventTime uintptr) uintptr {
+		if int32(idObject) != objidWindow {
+			return 0
+		}
+		onWinEvent(uint32(event), windows.HWND(hwnd))
+		return 0
+	})
+)
+
+// eventThread runs the message loop that win event hooks are delivered on
+type eventThread struct {
+	threadID uint32
+	done     chan struct{}
+}
+
+// startEventThread hooks winEvents on a dedicated OS thread
+func startEventThread() *eventThread {
+	t := &eventThread{done: make(chan struct{})}
+	ready := make(chan struct{})
+	go t.run(ready)
+	<-ready
+	return t
+}
+
+func (t *eventThread) run(ready chan struct{}) {
+	// hooks are delivered to the thread that set them, so this goroutine must not move
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(t.done)
+
+	t.threadID = windows.GetCurrentThreadId()
+
+	var hooks []uintptr
+	for _, event := range winEvents {
+		hook, _, err := setWinEventHookProc.Call(uintptr(event), uintptr(event), 0, winEventCallbackPtr, 0, 0, winEventOutOfContext|winEventSkipOwnProcess)
+		if hook == 0 {
+			slog.Error("Failed to hook win event", "event", fmt.Sprintf("0x%X", event), "error", err)
+			continue
+		}
+		hooks = append(hooks, hook)
+	}
+	// hotkeys are posted to the thread that registered them, next to the hooks
+	hotkeys := hotkeys()
+	hotkeyIDs := registerHotkeys(hotkeys)
+	// display notifications are dispatched to a window owned by this thread
+	displayWnd, err := createDisplayWindow()
+	if err != nil {
+		slog.Error("Failed to listen for display changes", "error", err)
+	}
+	close(ready)
+
+	var msg win.MSG
+	for win.GetMessage(&msg, 0, 0, 0) > 0 {
+		if msg.HWnd == 0 && msg.Message == win.WM_HOTKEY {
+			onHotkey(hotkeys, msg.WParam)
+			continue
+		}
+		win.TranslateMessage(&msg)
+		win.DispatchMessage(&msg)
+	}
+
+	releaseCursor("exit")
+	stopBroadcast()
+	unregisterHotkeys(hotkeyIDs)
+	if displayWnd != 0 {
+		win.DestroyWindow(displayWnd)
+	}
+	if displayTimer != nil {
+		displayTimer.Stop()
+	}
+	for _, hook := range hooks {
+		unhookWinEventProc.Call(hook)
+	}
+}
+
+// stop ends the message loop and waits for the hooks to be removed
+func (t *eventThread) stop() {
+	postThreadMessageProc.Call(uintptr(t.threadID), win.WM_QUIT, 0, 0)
+	<-t.done
+}
+
+// onWinEvent is called on the event thread for every hooked event on a top level window
+func onWinEvent(event uint32, hwnd windows.HWND) {
+	onCursorEvent(event, hwnd)
+	switch event {
+	case eventSystemForeground:
+		// an activated client rises above its backdrop, so bring the backdrop back beneath it
+		settingsWnd.Synchronize(updateBackdrops)
+		onForeground(hwnd)
+	case eventObjectLocationChange:
+		checkDrift(hwnd)
+	case eventObjectNameChange:
+		// games reset their title when they change zone or video mode
+		applyTitle(hwnd)
+	}
+}
@@ -0,0 +1,166 @@
+// Package winstyle converts GWL_STYLE and GWL_EXSTYLE window style masks to and from flag names
+package winstyle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Flag is a named window style bit, or a named combination of bits
+type Flag struct {
+	Name  string
+	Value uint32
+}
+
+// Styles are the GWL_STYLE flags of a top level window. Combined flags come before the bits they
+// cover so they are preferred when decoding
+var Styles = []Flag{
+	{"WS_POPUP", 0x80000000},
+	{"WS_CHILD", 0x40000000},
+	{"WS_MINIMIZE", 0x20000000},
+	{"WS_VISIBLE", 0x10000000},
+	{"WS_DISABLED", 0x08000000},
+	{"WS_CLIPSIBLINGS", 0x04000000},
+	{"WS_CLIPCHILDREN", 0x02000000},
+	{"WS_MAXIMIZE", 0x01000000},
+	{"WS_CAPTION", 0x00C00000},
+	{"WS_BORDER", 0x00800000},
+	{"WS_DLGFRAME", 0x00400000},
+	{"WS_VSCROLL", 0x00200000},
+	{"WS_HSCROLL", 0x00100000},
+	{"WS_SYSMENU", 0x00080000},
+	{"WS_THICKFRAME", 0x00040000},
+	{"WS_MINIMIZEBOX", 0x00020000},
+	{"WS_MAXIMIZEBOX", 0x00010000},
+}
+
+// ExStyles are the GWL_EXSTYLE flags
+var ExStyles = []Flag{
+	{"WS_EX_DLGMODALFRAME", 0x00000001},
+	{"WS_EX_NOPARENTNOTIFY", 0x00000004},
+	{"WS_EX_TOPMOST", 0x00000008},
+	{"WS_EX_ACCEPTFILES", 0x00000010},
+	{"WS_EX_TRANSPARENT", 0x00000020},
+	{"WS_EX_MDICHILD", 0x00000040},
+	{"WS_EX_TOOLWINDOW", 0x00000080},
+	{"WS_EX_WINDOWEDGE", 0x00000100},
+	{"WS_EX_CLIENTEDGE", 0x00000200},
+	{"WS_EX_CONTEXTHELP", 0x00000400},
+	{"WS_EX_RIGHT", 0x00001000},
+	{"WS_EX_RTLREADING", 0x00002000},
+	{"WS_EX_LEFTSCROLLBAR", 0x00004000},
+	{"WS_EX_CONTROLPARENT", 0x00010000},
+	{"WS_EX_STATICEDGE", 0x00020000},
+	{"WS_EX_APPWINDOW", 0x00040000},
+	{"WS_EX_LAYERED", 0x00080000},
+	{"WS_EX_NOINHERITLAYOUT", 0x00100000},
+	{"WS_EX_NOREDIRECTIONBITMAP", 0x00200000},
+	{"WS_EX_LAYOUTRTL", 0x00400000},
+	{"WS_EX_COMPOSITED", 0x02000000},
+	{"WS_EX_NOACTIVATE", 0x08000000},
+}
+
+// StyleAliases are alternate or combined GWL_STYLE names accepted when parsing, but never produced
+var StyleAliases = []Flag{
+	{"WS_OVERLAPPED", 0x00000000},
+	{"WS_TILED", 0x00000000},
+	{"WS_ICONIC", 0x20000000},
+	{"WS_SIZEBOX", 0x00040000},
+	{"WS_GROUP", 0x00020000},
+	{"WS_TABSTOP", 0x00010000},
+	{"WS_OVERLAPPEDWINDOW", 0x00CF0000},
+	{"WS_TILEDWINDOW", 0x00CF0000},
+	{"WS_POPUPWINDOW", 0x80880000},
+}
+
+// ExStyleAliases are combined GWL_EXSTYLE names accepted when parsing, but never produced
+var ExStyleAliases = []Flag{
+	{"WS_EX_LEFT", 0x00000000},
+	{"WS_EX_LTRREADING", 0x00000000},
+	{"WS_EX_RIGHTSCROLLBAR", 0x00000000},
+	{"WS_EX_OVERLAPPEDWINDOW", 0x00000300},
+	{"WS_EX_PALETTEWINDOW", 0x00000188},
+}
+
+// FormatStyle returns a GWL_STYLE mask as names joined by |, e.g. WS_VISIBLE|WS_CAPTION
+func FormatStyle(mask uint32) string {
+	return Format(Styles, mask)
+}
+
+// FormatExStyle returns a GWL_EXSTYLE mask as names joined by |
+func FormatExStyle(mask uint32) string {
+	return Format(ExStyles, mask)
+}
+
+// ParseStyle parses GWL_STYLE names separated by |, commas or spaces
+func ParseStyle(value string) (uint32, error) {
+	return Parse(value, Styles, StyleAliases)
+}
+
+// ParseExStyle parses GWL_EXSTYLE names separated by |, commas or spaces
+func ParseExStyle(value string) (uint32, error) {
+	return Parse(value, ExStyles, ExStyleAliases)
+}
+
+// Format returns a mask as names from table joined by |. An empty mask is returned as 0
+func Format(table []Flag, mask uint32) string {
+	names := Names(table, mask)
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// Parse converts names separated by |, commas or spaces into a mask. Names are case insensitive
+// and may be taken from any of tables. Hex (0x...) and decimal values are accepted as is
+func Parse(value string, tables ...[]Flag) (uint32, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '|' || r == ',' || r == ' ' || r == '\t'
+	})
+	mask := uint32(0)
+	for _, field := range fields {
+		flag, err := lookup(field, tables)
+		if err != nil {
+			return 0, err
+		}
+		mask |= flag
+	}
+	return mask, nil
+}
+
+// lookup returns the value of a single name or number
+func lookup(name string, tables [][]Flag) (uint32, error) {
+	for _, table := range tables {
+		for _, flag := range table {
+			if strings.EqualFold(flag.Name, name) {
+				return flag.Value, nil
+			}
+		}
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		val, err := strconv.ParseUint(name, 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("parse %s: %w", name, err)
+		}
+		return uint32(val), nil
+	}
+	return 0, fmt.Errorf("unknown style flag %s", name)
+}
+
+// Names decodes a mask into flag names in table order. Bits no flag covers are returned as a hex value
+func Names(table []Flag, mask uint32) []string {
+	names := []string{}
+	covered := uint32(0)
+	for _, flag := range table {
+		if flag.Value == 0 || mask&flag.Value != flag.Value || covered&flag.Value == flag.Value {
+			continue
+		}
+		names = append(names, flag.Name)
+		covered |= flag.Value
+	}
+	if rest := mask &^ covered; rest != 0 {
+		names = append(names, fmt.Sprintf("0x%08X", rest))
+	}
+	return names
+}
@@ -0,0 +1,165 @@
+package winstyle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatStyle(t *testing.T) {
+	tests := []struct {
+		mask uint32
+		want string
+	}{
+		{0, "0"},
+		{0x10000000, "WS_VISIBLE"},
+		// WS_CAPTION is preferred over the WS_BORDER and WS_DLGFRAME bits it covers
+		{0x00C00000, "WS_CAPTION"},
+		{0x00800000, "WS_BORDER"},
+		{0x00CF0000, "WS_CAPTION|WS_SYSMENU|WS_THICKFRAME|WS_MINIMIZEBOX|WS_MAXIMIZEBOX"},
+		{0x14CF0000, "WS_VISIBLE|WS_CLIPSIBLINGS|WS_CAPTION|WS_SYSMENU|WS_THICKFRAME|WS_MINIMIZEBOX|WS_MAXIMIZEBOX"},
+		{0x90000000, "WS_POPUP|WS_VISIBLE"},
+		// bits no flag covers are kept as hex
+		{0x10000001, "WS_VISIBLE|0x00000001"},
+	}
+	for _, tt := range tests {
+		got := FormatStyle(tt.mask)
+		if got != tt.want {
+			t.Errorf("FormatStyle(0x%08X): got %s, want %s", tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestFormatExStyle(t *testing.T) {
+	tests := []struct {
+		mask uint32
+		want string
+	}{
+		{0, "0"},
+		{0x00000008, "WS_EX_TOPMOST"},
+		{0x00040300, "WS_EX_WINDOWEDGE|WS_EX_CLIENTEDGE|WS_EX_APPWINDOW"},
+		{0x08000080, "WS_EX_TOOLWINDOW|WS_EX_NOACTIVATE"},
+		{0x00000002, "0x00000002"},
+	}
+	for _, tt := range tests {
+		got := FormatExStyle(tt.mask)
+		if got != tt.want {
+			t.Errorf("FormatExStyle(0x%08X): got %s, want %s", tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint32
+	}{
+		{"", 0},
+		{"WS_VISIBLE", 0x10000000},
+		{"ws_visible", 0x10000000},
+		{"WS_POPUP|WS_VISIBLE", 0x90000000},
+		{"WS_POPUP, WS_VISIBLE", 0x90000000},
+		{"WS_POPUP WS_VISIBLE\tWS_SYSMENU", 0x90080000},
+		{"WS_CAPTION", 0x00C00000},
+		{"WS_BORDER|WS_DLGFRAME", 0x00C00000},
+		{"WS_OVERLAPPEDWINDOW", 0x00CF0000},
+		{"WS_TILEDWINDOW", 0x00CF0000},
+		{"WS_OVERLAPPEDWINDOW|WS_CAPTION", 0x00CF0000},
+		{"WS_POPUPWINDOW", 0x80880000},
+		{"WS_OVERLAPPED", 0},
+		{"WS_SIZEBOX", 0x00040000},
+		{"0x00010000", 0x00010000},
+		{"65536|WS_VISIBLE", 0x10010000},
+	}
+	for _, tt := range tests {
+		got, err := ParseStyle(tt.value)
+		if err != nil {
+			t.Errorf("ParseStyle(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseStyle(%q): got 0x%08X, want 0x%08X", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseExStyle(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint32
+	}{
+		{"WS_EX_TOPMOST", 0x00000008},
+		{"WS_EX_TOOLWINDOW|WS_EX_NOACTIVATE", 0x08000080},
+		{"WS_EX_OVERLAPPEDWINDOW", 0x00000300},
+		{"WS_EX_PALETTEWINDOW", 0x00000188},
+		{"WS_EX_LEFT", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseExStyle(tt.value)
+		if err != nil {
+			t.Errorf("ParseExStyle(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseExStyle(%q): got 0x%08X, want 0x%08X", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) (uint32, error)
+		value string
+	}{
+		{"unknown style", ParseStyle, "WS_BOGUS"},
+		{"unknown among known", ParseStyle, "WS_VISIBLE|WS_BOGUS"},
+		{"ex-style given as style", ParseStyle, "WS_EX_TOPMOST"},
+		{"style given as ex-style", ParseExStyle, "WS_CAPTION"},
+		{"number out of range", ParseStyle, "0x100000000"},
+		{"malformed number", ParseStyle, "0xZZ"},
+	}
+	for _, tt := range tests {
+		_, err := tt.parse(tt.value)
+		if err == nil {
+			t.Errorf("%s: parsing %q succeeded", tt.name, tt.value)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	masks := []uint32{0x10000000, 0x00C00000, 0x14CF0000, 0x96000000, 0x10000001}
+	for _, mask := range masks {
+		got, err := ParseStyle(FormatStyle(mask))
+		if err != nil {
+			t.Errorf("round trip 0x%08X: %v", mask, err)
+			continue
+		}
+		if got != mask {
+			t.Errorf("round trip 0x%08X: got 0x%08X", mask, got)
+		}
+	}
+
+	exMasks := []uint32{0x00000008, 0x00040300, 0x08000080, 0x00000002}
+	for _, mask := range exMasks {
+		got, err := ParseExStyle(FormatExStyle(mask))
+		if err != nil {
+			t.Errorf("round trip ex 0x%08X: %v", mask, err)
+			continue
+		}
+		if got != mask {
+			t.Errorf("round trip ex 0x%08X: got 0x%08X", mask, got)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	got := Names(Styles, 0)
+	if len(got) != 0 {
+		t.Fatalf("Names of 0: got %v, want none", got)
+	}
+	got = Names(Styles, 0x00C80000)
+	want := []string{"WS_CAPTION", "WS_SYSMENU"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Names: got %v, want %v", got, want)
+	}
+}